@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tl "github.com/tuumbleweed/tintlog/logger"
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// malformed line options set from flags
+var (
+	// stop at the first line that isn't valid JSON (--strict)
+	strictParsing bool
+	// print malformed lines instead of only counting them (--mark-bad)
+	markBadLines bool
+	badLines     badLineReport
+)
+
+// maximum line numbers listed per file in the summary
+const maxReportedLines = 20
+
+// badLineReport counts malformed lines per source for the summary printed at the end
+type badLineReport struct {
+	count   int
+	sources []string
+	lines   map[string][]int
+	// report every line as soon as it is found, --follow never gets to the summary
+	immediate bool
+}
+
+func (r *badLineReport) add(source string, lineNo int) {
+	if r.lines == nil {
+		r.lines = make(map[string][]int)
+	}
+	if _, ok := r.lines[source]; !ok {
+		r.sources = append(r.sources, source)
+	}
+	r.lines[source] = append(r.lines[source], lineNo)
+	r.count++
+}
+
+// print the summary to stderr, nothing if every line was fine
+func (r *badLineReport) print() {
+	if r.count == 0 {
+		return
+	}
+	tl.Log(tl.Warning, palette.Yellow, "Skipped %s malformed lines", strconv.Itoa(r.count))
+	for _, source := range r.sources {
+		lines := r.lines[source]
+		refs := make([]string, 0, min(len(lines), maxReportedLines))
+		for _, n := range lines[:min(len(lines), maxReportedLines)] {
+			refs = append(refs, lineRef(n))
+		}
+		more := ""
+		if len(lines) > maxReportedLines {
+			more = fmt.Sprintf(" and %d more", len(lines)-maxReportedLines)
+		}
+		tl.Log(tl.Warning1, palette.Yellow, "%s: lines %s%s", source, strings.Join(refs, ", "), more)
+	}
+}
+
+// lineRef renders a line number, see lineFunc
+func lineRef(lineNo int) string {
+	switch {
+	case lineNo > 0:
+		return strconv.Itoa(lineNo)
+	case lineNo < 0:
+		return fmt.Sprintf("%d from the end", -lineNo)
+	default:
+		return "?"
+	}
+}
+
+/*
+handleBadLine is called for a line that isn't valid JSON, usually the last
+line of a file cut off by a crash. With --strict it stops reading,
+otherwise the line is counted for the summary and printed if --mark-bad is set.
+*/
+func handleBadLine(lineNo int, raw []byte, source string, parseErr error) (err error, errMsg string) {
+	if strictParsing {
+		return parseErr, fmt.Sprintf("Unable to json.Unmarshal line %s of %s: '%s'", lineRef(lineNo), source, string(raw))
+	}
+	badLines.add(source, lineNo)
+	if badLines.immediate {
+		tl.Log(tl.Warning, palette.Yellow, "Skipped malformed line %s of %s: %s", lineRef(lineNo), source, parseErr)
+	}
+	if markBadLines {
+		printBadLine(lineNo, raw, source, parseErr)
+	}
+	return nil, ""
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tl "github.com/tuumbleweed/tintlog/logger"
+)
+
+/*
+follower reads lines appended to a log file, like tail -f.
+
+It keeps the offset of the last complete line, so a line that is still
+being written is only returned once its newline arrives. If the file at
+path is recreated or truncated it starts over from the beginning of the new file.
+*/
+type follower struct {
+	path   string
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+	// bytes after the last newline, not yet a complete line
+	partial []byte
+	// complete lines read so far, -1 if reading started from the end (--tail)
+	lineNo int
+}
+
+func newFollower(path string) (*follower, error) {
+	fl := &follower{path: path}
+	if err := fl.open(); err != nil {
+		return nil, err
+	}
+	return fl, nil
+}
+
+func (fl *follower) open() error {
+	file, err := os.Open(fl.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if fl.file != nil {
+		fl.file.Close()
+	}
+	fl.file, fl.info, fl.offset, fl.partial, fl.lineNo = file, info, 0, nil, 0
+	return nil
+}
+
+func (fl *follower) close() {
+	if fl.file != nil {
+		fl.file.Close()
+	}
+}
+
+// reopenIfReplaced starts over if the path now points to another file or the file shrank.
+// A missing file is not an error: it may be recreated by the next rotation.
+func (fl *follower) reopenIfReplaced() error {
+	info, err := os.Stat(fl.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, fl.info) || info.Size() < fl.offset {
+		return fl.open()
+	}
+	return nil
+}
+
+// readLines calls fn for every complete line appended since the last call.
+// Returns whether anything new was read.
+func (fl *follower) readLines(fn lineFunc) (readAny bool, err error, errMsg string) {
+	if _, err := fl.file.Seek(fl.offset+int64(len(fl.partial)), io.SeekStart); err != nil {
+		return false, err, "Unable to seek in file"
+	}
+	chunk := make([]byte, 64*1024)
+	for {
+		n, readErr := fl.file.Read(chunk)
+		if n > 0 {
+			readAny = true
+			data := append(fl.partial, chunk[:n]...)
+			for {
+				nl := bytes.IndexByte(data, '\n')
+				if nl < 0 {
+					break
+				}
+				line := data[:nl]
+				fl.offset += int64(nl + 1)
+				data = data[nl+1:]
+				lineNo := 0
+				if fl.lineNo >= 0 {
+					fl.lineNo++
+					lineNo = fl.lineNo
+				}
+				if len(bytes.TrimSpace(line)) == 0 {
+					continue
+				}
+				if err, errMsg := fn(lineNo, line); err != nil {
+					return readAny, err, errMsg
+				}
+			}
+			fl.partial = append([]byte(nil), data...)
+		}
+		if readErr == io.EOF {
+			return readAny, nil, ""
+		}
+		if readErr != nil {
+			return readAny, readErr, "Error while reading file"
+		}
+	}
+}
+
+/*
+newestLogFile returns the newest uncompressed .jsonl file in dir.
+Files named by the logger (tl.Cfg.LogFileFormat, with an optional .N rotation suffix)
+are ordered by their name, since several may be created within one mod time tick.
+Other files are ordered by mod time and come before named ones.
+*/
+func newestLogFile(dir string) (path string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	type key struct {
+		named   bool
+		t       time.Time
+		seq     int
+		modTime time.Time
+	}
+	newer := func(a, b key) bool {
+		if a.named != b.named {
+			return a.named
+		}
+		if !a.t.Equal(b.t) {
+			return a.t.After(b.t)
+		}
+		if a.seq != b.seq {
+			return a.seq > b.seq
+		}
+		return a.modTime.After(b.modTime)
+	}
+
+	var best key
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		k := key{modTime: info.ModTime()}
+		k.t, k.seq, k.named = tl.ParseLogFileName(e.Name(), tl.Cfg.LogFileFormat)
+		if path == "" || newer(k, best) {
+			path, best = filepath.Join(dir, e.Name()), k
+		}
+	}
+	if path == "" {
+		return "", fmt.Errorf("no .jsonl files in %s", dir)
+	}
+	return path, nil
+}
+
+/*
+followLogFile prints lines of logPath as they are appended, until interrupted.
+
+logPath may be a file or a log directory. For a directory the newest .jsonl
+file is followed, and the reader switches to a newer file once it appears,
+which is how the logger rotates files (every file gets a new name).
+Existing lines are printed first, only the last tailCount if tailCount >= 0.
+Every line goes through the same filters as without --follow.
+*/
+func followLogFile(logPath string, f filter, tailCount int, interval time.Duration) (err error, errMsg string) {
+	if strings.HasSuffix(logPath, ".gz") {
+		return fmt.Errorf("can't follow compressed file %s", logPath), "Unable to follow file"
+	}
+	info, err := os.Stat(logPath)
+	if err != nil {
+		return err, "Unable to open file"
+	}
+	dir := ""
+	path := logPath
+	if info.IsDir() {
+		dir = logPath
+		path, err = newestLogFile(dir)
+		if err != nil {
+			return err, "Unable to find a log file to follow"
+		}
+	}
+
+	fl, err := newFollower(path)
+	if err != nil {
+		return err, "Unable to open file"
+	}
+	defer func() { fl.close() }()
+
+	// existing lines first, keeping only the last tailCount (read backwards, see lastLines)
+	process := func(lineNo int, line []byte) (error, string) {
+		return processLogLine(lineNo, line, f, sourceName(fl.path))
+	}
+	if tailCount < 0 {
+		if _, err, errMsg = fl.readLines(process); err != nil {
+			return err, errMsg
+		}
+	} else {
+		end, err := completeEnd(fl.file, fl.info.Size())
+		if err != nil {
+			return err, "Unable to read the end of file"
+		}
+		lines, err := lastLines(fl.file, end, tailCount)
+		if err != nil {
+			return err, "Unable to read the end of file"
+		}
+		if err, errMsg = processLines(lines, process); err != nil {
+			return err, errMsg
+		}
+		fl.offset, fl.lineNo = end, -1
+	}
+
+	for {
+		time.Sleep(interval)
+
+		if err = fl.reopenIfReplaced(); err != nil {
+			return err, "Unable to reopen file"
+		}
+		readAny, err, errMsg := fl.readLines(process)
+		if err != nil {
+			return err, errMsg
+		}
+		if readAny || dir == "" {
+			continue
+		}
+
+		// nothing new: the logger may have rotated to a new file
+		newest, err := newestLogFile(dir)
+		if err != nil || newest == fl.path {
+			continue
+		}
+		next, err := newFollower(newest)
+		if err != nil {
+			continue
+		}
+		// lines written to the old file between the read above and the rotation
+		if _, err, errMsg = fl.readLines(process); err != nil {
+			next.close()
+			return err, errMsg
+		}
+		fl.close()
+		fl = next
+		if _, err, errMsg = fl.readLines(process); err != nil {
+			return err, errMsg
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"regexp"
+)
+
+// compileGrep turns a --grep/--grep-v pattern into a regexp; nil if the pattern is empty.
+// Without --regex the pattern is a plain substring.
+func compileGrep(pattern string, isRegex, ignoreCase bool) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if !isRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// grepSpans returns every match of re in the rendered message
+func grepSpans(re *regexp.Regexp, plain string) []span {
+	if re == nil {
+		return nil
+	}
+	var spans []span
+	for _, m := range re.FindAllStringIndex(plain, -1) {
+		if m[1] > m[0] {
+			spans = append(spans, span{m[0], m[1]})
+		}
+	}
+	return spans
+}
@@ -4,11 +4,12 @@
 package main
 
 import (
-	"bufio"
+	"compress/gzip"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -18,15 +19,48 @@ import (
 )
 
 func main() {
-	logFile := flag.String("file", "", "File path to read.")
-	logLevel := flag.Int("level", 99, "Log level. Only print messages with log level <= this.")
+	// `log-reader tui [flags] files...` browses the lines interactively
+	tuiMode := len(os.Args) > 1 && os.Args[1] == "tui"
+	if tuiMode {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
+	var logFiles stringsFlag
+	flag.Var(&logFiles, "file", "File path to read, a glob like 'log/*.jsonl' or a log directory. Can be repeated, lines of several files are merged by time. Files ending with .gz are decompressed.")
+	showSource := flag.Bool("source", false, "Print the file each line came from (file name without .jsonl) before the line.")
+	logLevel := tl.LevelFlag("level", 99, "Log level, a number or a name like Warning5 or warning+3. Only print messages with log level <= this.")
+	maxLevel := tl.LevelFlag("max-level", 99, "Same as --level. If both are set the lower one wins.")
+	minLevel := tl.LevelFlag("min-level", tl.Critical, "Only print messages with log level >= this, a number or a name like Error.")
+	var tids intsFlag
+	flag.Var(&tids, "tid", "Only print lines from these goroutine ids, comma-separated. Can be repeated.")
+	var components stringsFlag
+	flag.Var(&components, "component", "Only print lines of these component loggers, comma-separated names or patterns like 'http.*' (see tl.MatchComponent). Can be repeated.")
+	var colors stringsFlag
+	flag.Var(&colors, "color", "Only print lines logged with these colorizer names, like RedBoldBackground, comma-separated. Can be repeated.")
 	startTimeStr := flag.String("start", "0000/Jan/01 00:00:00", "Start time in --time-format format. Keep empty to read from the beginning of the file.")
 	endTimeStr := flag.String("end", "9999/Dec/31 23:59:59", "End time in --time-format format. Keep empty to read to the end of the file.")
 	timeFormat := flag.String("time-format", tl.Cfg.TimeFormat, "Time format to use for --start and --end. Default is the same as default logger package time format.")
 	tail := flag.Int("tail", -1, "Number of lines to show with --tail.")
+	follow := flag.Bool("follow", false, "Keep printing lines as they are appended, like tail -f. --file can be a log directory to follow rotated files.")
+	grep := flag.String("grep", "", "Only print lines whose rendered message (without colors) contains this. Matches are highlighted.")
+	grepV := flag.String("grep-v", "", "Skip lines whose rendered message (without colors) contains this.")
+	isRegex := flag.Bool("regex", false, "Treat --grep and --grep-v as regular expressions (RE2 syntax).")
+	ignoreCase := flag.Bool("ignore-case", false, "Case-insensitive --grep and --grep-v.")
+	output := flag.String("output", outputColor, "Output format: color, plain, json (rendered message plus all fields, one object per line), logfmt or csv. color is downsampled to the terminal color depth and is plain when stdout is not a terminal, unless FORCE_COLOR is set.")
+	highlight := flag.String("highlight", "YellowBoldBackground", "Palette colorizer name for --grep matches.")
+	followInterval := flag.Duration("follow-interval", 250*time.Millisecond, "How often to check for new lines with --follow.")
+	strict := flag.Bool("strict", false, "Stop at the first line that isn't valid JSON. By default such lines are skipped and reported at the end.")
+	markBad := flag.Bool("mark-bad", false, "Print lines that aren't valid JSON, marked as malformed, instead of only reporting them at the end.")
+	repair := flag.Bool("repair", false, "Before reading, repair files whose last line was cut off (see tl.RepairLogFile). Not with --follow.")
+	stats := flag.Bool("stats", false, "Print a report instead of the lines: counts per level band and goroutine, top format strings, first and last time and a rate histogram. Filters apply. JSON with --output json.")
+	statsTop := flag.Int("stats-top", 10, "Number of goroutines and format strings listed by --stats.")
+	var fieldFilters stringsFlag
+	flag.Var(&fieldFilters, "field", "Only print lines with this field, as key=value (or just key). Can be repeated.")
 	flag.Parse()
 
-	if *logFile == "" {
+	// file paths can also be given as arguments
+	logFiles = append(logFiles, flag.Args()...)
+	if len(logFiles) == 0 {
 		fmt.Println("Need to specify --file")
 		os.Exit(1)
 	}
@@ -44,22 +78,180 @@ func main() {
 		return
 	}
 
+	fields, err := parseFieldFilters(fieldFilters)
+	if err != nil {
+		fmt.Println("Error parsing --field:", err)
+		return
+	}
+
+	grepRe, err := compileGrep(*grep, *isRegex, *ignoreCase)
+	if err != nil {
+		fmt.Println("Error parsing --grep:", err)
+		return
+	}
+	grepVRe, err := compileGrep(*grepV, *isRegex, *ignoreCase)
+	if err != nil {
+		fmt.Println("Error parsing --grep-v:", err)
+		return
+	}
+	highlightColor = pickColorizer(*highlight)
+	switch *output {
+	case outputColor, outputPlain, outputJSON, outputLogfmt, outputCSV:
+		outputFormat = *output
+		if outputFormat == outputColor {
+			// like the logger: no ANSI when stdout isn't a terminal, unless FORCE_COLOR is set
+			colorDepth = palette.DetectColorDepth()
+			if !tl.ShouldColor(os.Stdout, tl.Cfg.Color) {
+				colorDepth = palette.DepthNone
+			}
+		}
+	default:
+		fmt.Println("Unknown --output:", *output)
+		os.Exit(1)
+	}
+	defer flushOutput()
+	printSource = *showSource
+	strictParsing, markBadLines = *strict, *markBad
+	if *stats {
+		if *follow {
+			fmt.Println("--stats doesn't work with --follow")
+			os.Exit(1)
+		}
+		lineStats = newLogStats(max(*statsTop, 0))
+		// malformed lines are counted in the report
+		markBadLines = false
+	}
+	if tuiMode {
+		if *follow || *stats {
+			fmt.Println("tui doesn't work with --follow or --stats")
+			os.Exit(1)
+		}
+		tuiLines = new([]tuiLine)
+		markBadLines = false
+	}
+
+	f := filter{
+		minLevel:   *minLevel,
+		maxLevel:   min(*logLevel, *maxLevel),
+		tids:       tids.set(),
+		colors:     colors.set(),
+		components: components.list(),
+		startTime:  startTime,
+		endTime:    endTime,
+		fields:     fields,
+		grep:       grepRe,
+		grepV:      grepVRe,
+	}
+	// the tui starts at --level but loads every line for its level slider
+	tuiLevel := f.maxLevel
+	if tuiMode {
+		f.maxLevel = 99
+	}
+
 	// Read file with combined logic
-	err, errMsg := readLogFile(*logFile, tl.LogLevel(*logLevel), startTime, endTime, *tail)
+	var errMsg string
+	if *follow {
+		if len(logFiles) != 1 {
+			fmt.Println("--follow works with a single --file or log directory")
+			os.Exit(1)
+		}
+		if *repair {
+			fmt.Println("--repair doesn't work with --follow, the file may still be written to")
+			os.Exit(1)
+		}
+		badLines.immediate = true
+		flushEachLine = true
+		err, errMsg = followLogFile(logFiles[0], f, *tail, *followInterval)
+	} else {
+		var paths []string
+		paths, err = expandFiles(logFiles)
+		if err != nil {
+			fmt.Println("Error finding log files:", err)
+			os.Exit(1)
+		}
+		if *repair {
+			repairLogFiles(paths)
+		}
+		if len(paths) == 1 {
+			err, errMsg = readLogFile(paths[0], f, *tail)
+		} else {
+			err, errMsg = readLogFiles(paths, f, *tail)
+		}
+		switch {
+		case err == nil && tuiLines != nil:
+			err, errMsg = runTUI(*tuiLines, tuiLevel, *isRegex)
+		case lineStats != nil && outputFormat == outputJSON:
+			lineStats.printJSON()
+		case lineStats != nil:
+			lineStats.print(outputFormat == outputColor)
+		}
+		flushOutput()
+		badLines.print()
+	}
 	if err != nil {
 		tl.Log(tl.Info, palette.Red, "Err: '%s', errMsg: '%s'", err, errMsg)
 	}
 }
 
+// repairLogFiles fixes cut off last lines of uncompressed files, see tl.RepairLogFile
+func repairLogFiles(paths []string) {
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".gz") {
+			continue
+		}
+		dropped, err, errMsg := tl.RepairLogFile(path)
+		if err != nil {
+			tl.Log(tl.Info, palette.Red, "Err: '%s', errMsg: '%s'", err, errMsg)
+			continue
+		}
+		if len(dropped) > 0 {
+			tl.Log(tl.Notice, palette.Blue, "Repaired '%s', dropped %s bytes of a cut off line", path, strconv.Itoa(len(dropped)))
+		}
+	}
+}
+
+// filter holds the conditions a line must satisfy to be printed
+type filter struct {
+	minLevel  tl.LogLevel
+	maxLevel  tl.LogLevel
+	startTime time.Time
+	endTime   time.Time
+	// field key -> wanted value; nil value means the key only has to be present
+	fields map[string]*string
+	// goroutine ids and colorizer names, empty to skip
+	tids   map[int]bool
+	colors map[string]bool
+	// component patterns, empty to skip
+	components []string
+	// matched against the rendered message without colors, nil to skip
+	grep  *regexp.Regexp
+	grepV *regexp.Regexp
+}
+
+// output options set from flags
+var (
+	// colorizer for --grep matches
+	highlightColor = palette.YellowBoldBackground
+	// print the source file of each line (--source)
+	printSource bool
+	// colorizer for the source tag
+	sourceColor = palette.CyanDim
+)
+
 /*
 Read --file.
-If --tail is set, collect last N lines.
+If --tail is set, print only the last N lines: plain files are read backwards
+from the end, so memory stays bounded by N lines however big the file is.
+Otherwise the file is streamed line by line.
 For each line check if it's between startTime and endTime,
-and if its logging level is below or equal to --level.
+if its logging level is between --min-level and --level,
+if it comes from one of --tid goroutines and uses one of --color colorizers,
+if it has every --field
+and if its rendered message matches --grep and doesn't match --grep-v.
 If conditions are satisfied - print this message using fmt
 including all other parts of LogLine.
 */
-func readLogFile(logFile string, logLevel tl.LogLevel, startTime, endTime time.Time, tailCount int) (err error, errMsg string) {
+func readLogFile(logFile string, f filter, tailCount int) (err error, errMsg string) {
 	// Open the file
 	file, err := os.Open(logFile)
 	if err != nil {
@@ -67,111 +259,234 @@ func readLogFile(logFile string, logLevel tl.LogLevel, startTime, endTime time.T
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	source := sourceName(logFile)
+	process := func(lineNo int, line []byte) (error, string) { return processLogLine(lineNo, line, f, source) }
 
-	var buffer [][]byte
+	// rotated files may be compressed by the logger
+	if strings.HasSuffix(logFile, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return err, "Unable to open gzip stream"
+		}
+		defer zr.Close()
+		if tailCount < 0 {
+			return eachLine(zr, process)
+		}
+		// gzip can't be read backwards, keep a ring of the last N lines instead
+		lines, err, errMsg := lastLinesOf(zr, tailCount)
+		if err != nil {
+			return err, errMsg
+		}
+		return processLines(lines, process)
+	}
 
-	// First read all lines
-	for scanner.Scan() {
-		line := append([]byte(nil), scanner.Bytes()...) // copy
-		buffer = append(buffer, line)
+	if tailCount < 0 {
+		return eachLine(file, process)
 	}
-	if err := scanner.Err(); err != nil {
-		return err, "Scanner error while reading file"
+	info, err := file.Stat()
+	if err != nil {
+		return err, "Unable to stat file"
 	}
-
-	// Trim to last N lines if --tail is set
-	if tailCount >= 0 && len(buffer) > tailCount {
-		buffer = buffer[len(buffer)-tailCount:]
+	lines, err := lastLines(file, info.Size(), tailCount)
+	if err != nil {
+		return err, "Unable to read the end of file"
 	}
+	return processLines(lines, process)
+}
 
-	// Process and print each line
-	for _, line := range buffer {
-		err, errMsg = processLogLine(line, logLevel, startTime, endTime)
-		if err != nil {
+// processLines handles the last lines of a file, numbered from its end
+func processLines(lines [][]byte, process lineFunc) (err error, errMsg string) {
+	for i, line := range lines {
+		if err, errMsg = process(i-len(lines), line); err != nil {
 			return err, errMsg
 		}
 	}
-
 	return nil, ""
 }
 
-func processLogLine(logLineBytes []byte, logLevel tl.LogLevel, startTime, endTime time.Time) (err error, errMsg string) {
+func processLogLine(lineNo int, logLineBytes []byte, f filter, source string) (err error, errMsg string) {
 	var logLine tl.LogLine
 	// Unmarshal the JSON into the struct
 	err = json.Unmarshal(logLineBytes, &logLine)
 	if err != nil {
-		return err, fmt.Sprintf("Unable to json.Unmarshal line: '%s'", string(logLineBytes))
+		return handleBadLine(lineNo, logLineBytes, source, err)
 	}
+	return processParsedLine(logLine, f, source)
+}
 
+// processParsedLine filters and prints a line; source is the file it came from
+func processParsedLine(logLine tl.LogLine, f filter, source string) (err error, errMsg string) {
 	// first check time
-	if !(AfterOrEqual(logLine.Time, startTime) && BeforeOrEqual(logLine.Time, endTime)) {
+	if !(AfterOrEqual(logLine.Time, f.startTime) && BeforeOrEqual(logLine.Time, f.endTime)) {
 		// skip the line if it's not within our time range
 		return nil, ""
 	}
 	// then check log level
-	if logLine.Level > logLevel {
-		// skip the line if log level is above specified
+	if logLine.Level > f.maxLevel || logLine.Level < f.minLevel {
+		// skip the line if log level is outside of the range
+		return nil, ""
+	}
+	// then check goroutine and color
+	if len(f.tids) > 0 && !f.tids[logLine.TID] {
+		return nil, ""
+	}
+	if len(f.colors) > 0 && !f.colors[logLine.Color] {
+		return nil, ""
+	}
+	if len(f.components) > 0 && !matchComponent(logLine.Component, f.components) {
+		return nil, ""
+	}
+	// then check fields
+	if !matchFields(logLine.Fields, f.fields) {
 		return nil, ""
 	}
 
-	// now print it
-	printLogLine(logLine)
+	// then check the rendered message
+	plain, argSpans := renderMessage(logLine)
+	matches := grepSpans(f.grep, plain)
+	if f.grep != nil && len(matches) == 0 {
+		return nil, ""
+	}
+	if f.grepV != nil && f.grepV.MatchString(plain) {
+		return nil, ""
+	}
+
+	// now print it, or only count it for --stats, or keep it for the tui
+	if lineStats != nil {
+		lineStats.add(logLine)
+		return nil, ""
+	}
+	if tuiLines != nil {
+		*tuiLines = append(*tuiLines, tuiLine{logLine: logLine, source: source, plain: plain, argSpans: argSpans})
+		return nil, ""
+	}
+	printLogLine(logLine, plain, argSpans, matches, source)
 
 	return nil, ""
 }
 
-func AfterOrEqual(t, u time.Time) bool {
-	return t.After(u) || t.Equal(u)
+// stringsFlag collects every value of a repeated flag
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
 
-func BeforeOrEqual(t, u time.Time) bool {
-	return t.Before(u) || t.Equal(u)
+// set splits comma-separated values into a set, nil if there are none
+func (s stringsFlag) set() map[string]bool {
+	if len(s) == 0 {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, part := range s.list() {
+		set[part] = true
+	}
+	return set
 }
 
-// pick a colorizer by name, fallback to NoColor
-func pickColorizer(name string) palette.Colorizer {
-	if c, ok := palette.Colorizers[name]; ok && c.Fn != nil {
-		return c
+// list splits comma-separated values, keeping their order
+func (s stringsFlag) list() []string {
+	var list []string
+	for _, v := range s {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, part)
+			}
+		}
 	}
-	return palette.Colorizers["NoColor"]
+	return list
 }
 
-func printLogLine(logLine tl.LogLine) {
-	// choose colors
-	timeColorizer := tl.Cfg.LogTimeColor             // e.g. "Gray" or "#8899aa"
-	logLineColorizer := pickColorizer(logLine.Color) // e.g. "Green", "RedBoldBackground"
+// intsFlag collects comma-separated integers of a repeated flag
+type intsFlag []int
+
+func (s *intsFlag) String() string { return fmt.Sprint([]int(*s)) }
+
+func (s *intsFlag) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return err
+		}
+		*s = append(*s, n)
+	}
+	return nil
+}
 
-	// build fields
-	timeStr := timeColorizer.Apply(logLine.Time.Format(tl.Cfg.TimeFormat))
-	levelStr := logLineColorizer.Apply(logLine.Level.String())
+func (s intsFlag) set() map[int]bool {
+	if len(s) == 0 {
+		return nil
+	}
+	set := make(map[int]bool, len(s))
+	for _, n := range s {
+		set[n] = true
+	}
+	return set
+}
 
-	tidPart := ""
-	if logLine.TID > 0 { // NOTE: field is TID (not TId)
-		tidPart = "[" + logLineColorizer.Apply(strconv.Itoa(logLine.TID)) + "]"
+// parse --field values: "key=value" requires that value, "key" only requires the key
+func parseFieldFilters(values []string) (map[string]*string, error) {
+	if len(values) == 0 {
+		return nil, nil
 	}
+	fields := make(map[string]*string, len(values))
+	for _, v := range values {
+		key, value, hasValue := strings.Cut(v, "=")
+		if key == "" {
+			return nil, fmt.Errorf("empty key in %q", v)
+		}
+		if hasValue {
+			fields[key] = &value
+		} else {
+			fields[key] = nil
+		}
+	}
+	return fields, nil
+}
 
-	// render message (use Format+Args if provided)
-	msg := logLine.Format
-	if strings.TrimSpace(msg) == "" {
-		msg = "%v"
+// every wanted field must be present, and equal if a value was given.
+// Values are compared in their rendered form, so key=42 matches both 42 and "42".
+func matchFields(lineFields map[string]any, want map[string]*string) bool {
+	for key, value := range want {
+		got, ok := lineFields[key]
+		if !ok {
+			return false
+		}
+		if value != nil && tl.PrettyForStderr(got) != *value {
+			return false
+		}
 	}
+	return true
+}
 
-	// color the arguments
-	var coloredArgs []any
-	if len(logLine.Args) > 0 {
-		for _, arg := range logLine.Args {
-			coloredArg := tl.PrettyForStderr(arg)
-			coloredArgs = append(coloredArgs, logLineColorizer.Apply(coloredArg))
+// a line without a component never matches
+func matchComponent(component string, patterns []string) bool {
+	if component == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if tl.MatchComponent(pattern, component) {
+			return true
 		}
-		msg = fmt.Sprintf(msg, coloredArgs...)
 	}
+	return false
+}
 
-	// final line
-	// Example: 2025-11-09T18:19:26-05:00 [ERROR][1] message...
-	if tidPart != "" {
-		fmt.Printf("%s [%s]%s %s\n", timeStr, levelStr, tidPart, msg)
-	} else {
-		fmt.Printf("%s [%s] %s\n", timeStr, levelStr, msg)
+func AfterOrEqual(t, u time.Time) bool {
+	return t.After(u) || t.Equal(u)
+}
+
+func BeforeOrEqual(t, u time.Time) bool {
+	return t.Before(u) || t.Equal(u)
+}
+
+// pick a colorizer by name, fallback to NoColor
+func pickColorizer(name string) palette.Colorizer {
+	if c, ok := palette.Colorizers[name]; ok && c.Fn != nil {
+		return c
 	}
+	return palette.Colorizers["NoColor"]
 }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tl "github.com/tuumbleweed/tintlog/logger"
+)
+
+/*
+expandFiles turns --file values into file paths:
+a glob like "log/*.jsonl" expands to matching files, a directory expands to
+every .jsonl and .jsonl.gz file in it, anything else is taken as a file path.
+Duplicates are dropped, order is kept.
+*/
+func expandFiles(values []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, v := range values {
+		if strings.ContainsAny(v, "*?[") {
+			matches, err := filepath.Glob(v)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", v)
+			}
+			for _, m := range matches {
+				add(m)
+			}
+			continue
+		}
+		info, err := os.Stat(v)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(v)
+			continue
+		}
+		entries, err := os.ReadDir(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && (strings.HasSuffix(e.Name(), ".jsonl") || strings.HasSuffix(e.Name(), ".jsonl.gz")) {
+				add(filepath.Join(v, e.Name()))
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no log files in %s", strings.Join(values, ", "))
+	}
+	return paths, nil
+}
+
+// sourceName is the tag printed with --source: the file name without .jsonl(.gz)
+func sourceName(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, ".gz")
+	return strings.TrimSuffix(name, ".jsonl")
+}
+
+// lineSource yields raw lines of one file with their numbers (see lineFunc), io.EOF at the end
+type lineSource interface {
+	nextLine() (lineNo int, line []byte, err error)
+}
+
+// streamSource reads a (possibly gzipped) file line by line
+type streamSource struct {
+	br     *bufio.Reader
+	lineNo int
+}
+
+func (s *streamSource) nextLine() (int, []byte, error) {
+	for {
+		line, err := s.br.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		s.lineNo++
+		if len(bytes.TrimSpace(line)) > 0 {
+			return s.lineNo, line, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+// sliceSource yields lines collected beforehand, for --tail
+type sliceSource struct {
+	lines [][]byte
+}
+
+func (s *sliceSource) nextLine() (int, []byte, error) {
+	if len(s.lines) == 0 {
+		return 0, nil, io.EOF
+	}
+	lineNo, line := -len(s.lines), s.lines[0]
+	s.lines = s.lines[1:]
+	return lineNo, line, nil
+}
+
+// openSource opens path for merging; with tailCount >= 0 only its last tailCount lines are read.
+// The returned closer must be called when done.
+func openSource(path string, tailCount int) (src lineSource, closer func(), err error, errMsg string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err, fmt.Sprintf("Unable to open file '%s'", path)
+	}
+	var reader io.Reader = file
+	closer = func() { file.Close() }
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err, fmt.Sprintf("Unable to open gzip stream '%s'", path)
+		}
+		reader = zr
+		closer = func() { zr.Close(); file.Close() }
+	}
+	if tailCount < 0 {
+		return &streamSource{br: bufio.NewReaderSize(reader, readChunkSize)}, closer, nil, ""
+	}
+
+	var lines [][]byte
+	if strings.HasSuffix(path, ".gz") {
+		lines, err, errMsg = lastLinesOf(reader, tailCount)
+	} else {
+		var info os.FileInfo
+		if info, err = file.Stat(); err == nil {
+			lines, err = lastLines(file, info.Size(), tailCount)
+		}
+		errMsg = "Unable to read the end of file"
+	}
+	if err != nil {
+		closer()
+		return nil, nil, err, errMsg
+	}
+	return &sliceSource{lines: lines}, closer, nil, ""
+}
+
+// mergeItem is the next line of one source
+type mergeItem struct {
+	logLine tl.LogLine
+	src     int
+}
+
+// mergeHeap orders items by time, then by source order so equal times stay stable
+type mergeHeap []mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].logLine.Time.Equal(h[j].logLine.Time) {
+		return h[i].logLine.Time.Before(h[j].logLine.Time)
+	}
+	return h[i].src < h[j].src
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(mergeItem)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+/*
+readLogFiles k-way merges several files by line time into one ordered stream.
+Each file is expected to be ordered by time already, as the logger writes it.
+Only one line per file is held in memory (N per file with --tail, and then
+only the last N of the merged stream are printed).
+*/
+func readLogFiles(paths []string, f filter, tailCount int) (err error, errMsg string) {
+	sources := make([]lineSource, len(paths))
+	for i, path := range paths {
+		src, closer, err, errMsg := openSource(path, tailCount)
+		if err != nil {
+			return err, errMsg
+		}
+		defer closer()
+		sources[i] = src
+	}
+
+	h := &mergeHeap{}
+	// push the next parsable line of source i
+	advance := func(i int) (err error, errMsg string) {
+		for {
+			lineNo, raw, err := sources[i].nextLine()
+			if err == io.EOF {
+				return nil, ""
+			}
+			if err != nil {
+				return err, fmt.Sprintf("Error while reading file '%s'", paths[i])
+			}
+			var logLine tl.LogLine
+			if err = json.Unmarshal(raw, &logLine); err != nil {
+				// malformed lines have no time to merge by, they are handled right away
+				if err, errMsg := handleBadLine(lineNo, raw, sourceName(paths[i]), err); err != nil {
+					return err, errMsg
+				}
+				continue
+			}
+			heap.Push(h, mergeItem{logLine: logLine, src: i})
+			return nil, ""
+		}
+	}
+	for i := range sources {
+		if err, errMsg = advance(i); err != nil {
+			return err, errMsg
+		}
+	}
+
+	var merged []mergeItem
+	for h.Len() > 0 {
+		item := heap.Pop(h).(mergeItem)
+		if tailCount >= 0 {
+			merged = append(merged, item)
+		} else if err, errMsg = processParsedLine(item.logLine, f, sourceName(paths[item.src])); err != nil {
+			return err, errMsg
+		}
+		if err, errMsg = advance(item.src); err != nil {
+			return err, errMsg
+		}
+	}
+
+	if tailCount >= 0 {
+		if len(merged) > tailCount {
+			merged = merged[len(merged)-tailCount:]
+		}
+		for _, item := range merged {
+			if err, errMsg = processParsedLine(item.logLine, f, sourceName(paths[item.src])); err != nil {
+				return err, errMsg
+			}
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	tl "github.com/tuumbleweed/tintlog/logger"
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// values of --output
+const (
+	outputColor  = "color"
+	outputPlain  = "plain"
+	outputJSON   = "json"
+	outputLogfmt = "logfmt"
+	outputCSV    = "csv"
+)
+
+var (
+	outputFormat = outputColor
+	stdout       = bufio.NewWriter(os.Stdout)
+	csvWriter    *csv.Writer
+	// flush after every line, so --follow shows lines right away; otherwise only at the end
+	flushEachLine bool
+	// colors of --output color are downsampled to what the terminal supports
+	colorDepth = palette.DepthTrueColor
+)
+
+// flushOutput writes buffered output
+func flushOutput() {
+	if csvWriter != nil {
+		csvWriter.Flush()
+	}
+	_ = stdout.Flush()
+}
+
+// printLogLine prints a line that passed the filters in --output format.
+// plain is the rendered message without colors, see renderMessage.
+func printLogLine(logLine tl.LogLine, plain string, argSpans, matches []span, source string) {
+	// machine formats drop the trailing newline of the format string
+	message := strings.TrimRight(plain, "\n")
+	switch outputFormat {
+	case outputPlain:
+		printTextLine(logLine, plain, source, false)
+	case outputJSON:
+		printJSONLine(logLine, message, source)
+	case outputLogfmt:
+		printLogfmtLine(logLine, message, source)
+	case outputCSV:
+		printCSVLine(logLine, message, source)
+	default:
+		msg := colorMessage(plain, argSpans, matches, pickColorizer(logLine.Color), highlightColor)
+		printTextLine(logLine, msg, source, true)
+	}
+	if flushEachLine {
+		flushOutput()
+	}
+}
+
+// jsonLine is a --output json line: the logged line plus its rendered message
+type jsonLine struct {
+	Time      time.Time      `json:"time"`
+	Level     int            `json:"level"`
+	LevelName string         `json:"level_name"`
+	TID       int            `json:"tid,omitempty"`
+	Component string         `json:"component,omitempty"`
+	Color     string         `json:"color,omitempty"`
+	Source    string         `json:"source,omitempty"`
+	Caller    *tl.Caller     `json:"caller,omitempty"`
+	Message   string         `json:"message"`
+	Format    string         `json:"format"`
+	Args      []any          `json:"args"`
+	Fields    map[string]any `json:"fields,omitempty"`
+	Stack     string         `json:"stack,omitempty"`
+}
+
+func printJSONLine(logLine tl.LogLine, plain string, source string) {
+	line := jsonLine{
+		Time:      logLine.Time,
+		Level:     int(logLine.Level),
+		LevelName: logLine.Level.String(),
+		TID:       logLine.TID,
+		Component: logLine.Component,
+		Color:     logLine.Color,
+		Caller:    logLine.Caller,
+		Message:   plain,
+		Format:    logLine.Format,
+		Args:      logLine.Args,
+		Fields:    logLine.Fields,
+		Stack:     logLine.Stack,
+	}
+	if printSource {
+		line.Source = source
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	stdout.Write(append(b, '\n'))
+}
+
+// printLogfmtLine prints time=... level=... msg=... followed by the line fields
+func printLogfmtLine(logLine tl.LogLine, plain string, source string) {
+	fields := []tl.Field{
+		tl.F("time", logLine.Time.Format(time.RFC3339Nano)),
+		tl.F("level", logLine.Level.String()),
+	}
+	if logLine.TID > 0 {
+		fields = append(fields, tl.F("tid", logLine.TID))
+	}
+	if logLine.Component != "" {
+		fields = append(fields, tl.F("component", logLine.Component))
+	}
+	if logLine.Color != "" {
+		fields = append(fields, tl.F("color", logLine.Color))
+	}
+	if printSource {
+		fields = append(fields, tl.F("source", source))
+	}
+	if logLine.Caller != nil {
+		fields = append(fields, tl.F("caller", logLine.Caller.Short()))
+	}
+	fields = append(fields, tl.F("msg", plain))
+	fields = append(fields, tl.SortedFields(logLine.Fields)...)
+	fmt.Fprintln(stdout, tl.RenderFields(fields, palette.NoColor))
+}
+
+// csv columns, the header is printed before the first line
+var csvHeader = []string{"time", "level", "level_name", "tid", "component", "color", "source", "caller", "message", "fields"}
+
+func printCSVLine(logLine tl.LogLine, plain string, source string) {
+	if csvWriter == nil {
+		csvWriter = csv.NewWriter(stdout)
+		_ = csvWriter.Write(csvHeader)
+	}
+	tid, caller, fields := "", "", ""
+	if logLine.TID > 0 {
+		tid = strconv.Itoa(logLine.TID)
+	}
+	if logLine.Caller != nil {
+		caller = logLine.Caller.Short()
+	}
+	if len(logLine.Fields) > 0 {
+		if b, err := json.Marshal(logLine.Fields); err == nil {
+			fields = string(b)
+		}
+	}
+	if !printSource {
+		source = ""
+	}
+	_ = csvWriter.Write([]string{
+		logLine.Time.Format(time.RFC3339Nano),
+		strconv.Itoa(int(logLine.Level)),
+		logLine.Level.String(),
+		tid,
+		logLine.Component,
+		logLine.Color,
+		source,
+		caller,
+		plain,
+		fields,
+	})
+}
+
+// printTextLine prints a line the way the logger does, prefixed with [source] if --source is set.
+// Without colored no ANSI sequences are printed.
+func printTextLine(logLine tl.LogLine, msg string, source string, colored bool) {
+	line := formatTextLine(logLine, msg, source, colored)
+	if colored {
+		line = palette.Downsample(line, colorDepth)
+	}
+	fmt.Fprintln(stdout, line)
+}
+
+// formatTextLine formats a line for printTextLine, without the final newline
+func formatTextLine(logLine tl.LogLine, msg string, source string, colored bool) string {
+	// choose colors
+	timeColorizer := tl.Cfg.LogTimeColor             // e.g. "Gray" or "#8899aa"
+	logLineColorizer := pickColorizer(logLine.Color) // e.g. "Green", "RedBoldBackground"
+	callerColorizer, sourceColorizer := tl.Cfg.LogCallerColor, sourceColor
+	if !colored {
+		timeColorizer, logLineColorizer = palette.NoColor, palette.NoColor
+		callerColorizer, sourceColorizer = palette.NoColor, palette.NoColor
+	}
+
+	// build fields
+	timeStr := timeColorizer.Apply(logLine.Time.Format(tl.Cfg.TimeFormat))
+	levelStr := logLineColorizer.Apply(logLine.Level.String())
+
+	tidPart := ""
+	if logLine.TID > 0 { // NOTE: field is TID (not TId)
+		tidPart = "[" + logLineColorizer.Apply(strconv.Itoa(logLine.TID)) + "]"
+	}
+	if logLine.Component != "" {
+		tidPart += "[" + logLineColorizer.Apply(logLine.Component) + "]"
+	}
+
+	// caller goes before the message, dim like the logger prints it
+	callerPart := ""
+	if logLine.Caller != nil {
+		callerPart = callerColorizer.Apply(logLine.Caller.Short()) + " "
+	}
+
+	// fields go after the message
+	if len(logLine.Fields) > 0 {
+		msg = strings.TrimSuffix(msg, "\n") + " " + tl.RenderFields(tl.SortedFields(logLine.Fields), logLineColorizer)
+	}
+
+	if printSource {
+		timeStr = "[" + sourceColorizer.Apply(source) + "] " + timeStr
+	}
+
+	// final line
+	// Example: 2025-11-09T18:19:26-05:00 [ERROR][1] message...
+	if tidPart != "" {
+		return fmt.Sprintf("%s [%s]%s %s%s", timeStr, levelStr, tidPart, callerPart, msg)
+	}
+	return fmt.Sprintf("%s [%s] %s%s", timeStr, levelStr, callerPart, msg)
+}
+
+// printBadLine prints a malformed line (--mark-bad) in --output format, as raw text
+func printBadLine(lineNo int, raw []byte, source string, parseErr error) {
+	if !printSource {
+		source = ""
+	}
+	switch outputFormat {
+	case outputJSON:
+		b, err := json.Marshal(struct {
+			Malformed bool   `json:"malformed"`
+			Source    string `json:"source,omitempty"`
+			Line      int    `json:"line,omitempty"`
+			Error     string `json:"error"`
+			Raw       string `json:"raw"`
+		}{true, source, lineNo, parseErr.Error(), string(raw)})
+		if err == nil {
+			stdout.Write(append(b, '\n'))
+		}
+	case outputLogfmt:
+		fields := []tl.Field{tl.F("malformed", true)}
+		if source != "" {
+			fields = append(fields, tl.F("source", source))
+		}
+		fields = append(fields, tl.F("line", lineRef(lineNo)), tl.F("error", parseErr.Error()), tl.F("raw", string(raw)))
+		fmt.Fprintln(stdout, tl.RenderFields(fields, palette.NoColor))
+	case outputCSV:
+		if csvWriter == nil {
+			csvWriter = csv.NewWriter(stdout)
+			_ = csvWriter.Write(csvHeader)
+		}
+		_ = csvWriter.Write([]string{"", "", "malformed", "", "", "", source, "", string(raw), ""})
+	default:
+		mark := "malformed line " + lineRef(lineNo) + ":"
+		if outputFormat == outputColor {
+			mark = palette.Downsample(palette.RedBold.Apply(mark), colorDepth)
+			if source != "" {
+				source = palette.Downsample(sourceColor.Apply(source), colorDepth)
+			}
+		}
+		if source != "" {
+			mark = "[" + source + "] " + mark
+		}
+		fmt.Fprintf(stdout, "%s %s\n", mark, raw)
+	}
+	if flushEachLine {
+		flushOutput()
+	}
+}
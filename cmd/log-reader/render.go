@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tl "github.com/tuumbleweed/tintlog/logger"
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// span is a [start, end) byte range of a rendered message
+type span struct{ start, end int }
+
+// private-use runes marking where an argument starts and ends in the formatted message
+const (
+	argStart = "\uE000"
+	argEnd   = "\uE001"
+)
+
+/*
+renderMessage builds the uncolored message from Format and Args the same way the
+logger does (every arg goes through tl.PrettyForStderr), and returns where each
+argument ended up so it can be colored afterwards.
+*/
+func renderMessage(logLine tl.LogLine) (plain string, argSpans []span) {
+	// formatted even without args, like the logger: "100%% done" is "100% done"
+	msg := logLine.Format
+	if strings.TrimSpace(msg) == "" && len(logLine.Args) > 0 {
+		msg = "%v"
+	}
+
+	marked := make([]any, len(logLine.Args))
+	for i, arg := range logLine.Args {
+		marked[i] = argStart + tl.PrettyForStderr(arg) + argEnd
+	}
+	formatted := fmt.Sprintf(msg, marked...)
+
+	var b strings.Builder
+	b.Grow(len(formatted))
+	start := -1
+	for len(formatted) > 0 {
+		switch {
+		case strings.HasPrefix(formatted, argStart):
+			start = b.Len()
+			formatted = formatted[len(argStart):]
+		case strings.HasPrefix(formatted, argEnd):
+			if start >= 0 {
+				argSpans = append(argSpans, span{start, b.Len()})
+				start = -1
+			}
+			formatted = formatted[len(argEnd):]
+		default:
+			b.WriteByte(formatted[0])
+			formatted = formatted[1:]
+		}
+	}
+	return b.String(), argSpans
+}
+
+/*
+colorMessage colors a rendered message: argument spans with argColor and
+highlighted spans (grep matches) with highlightColor, which wins where they overlap.
+*/
+func colorMessage(plain string, argSpans, highlights []span, argColor, highlightColor palette.Colorizer) string {
+	// cut the message at every span boundary, then color each piece
+	cuts := []int{0, len(plain)}
+	for _, s := range append(append([]span(nil), argSpans...), highlights...) {
+		cuts = append(cuts, s.start, s.end)
+	}
+	sort.Ints(cuts)
+
+	inAny := func(spans []span, pos int) bool {
+		for _, s := range spans {
+			if s.start <= pos && pos < s.end {
+				return true
+			}
+		}
+		return false
+	}
+
+	var b strings.Builder
+	for i := 0; i+1 < len(cuts); i++ {
+		from, to := cuts[i], cuts[i+1]
+		if from == to {
+			continue
+		}
+		piece := plain[from:to]
+		switch {
+		case inAny(highlights, from):
+			b.WriteString(highlightColor.Apply(piece))
+		case inAny(argSpans, from):
+			b.WriteString(argColor.Apply(piece))
+		default:
+			b.WriteString(piece)
+		}
+	}
+	return b.String()
+}
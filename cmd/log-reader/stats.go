@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tl "github.com/tuumbleweed/tintlog/logger"
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// collects --stats instead of printing lines, nil without --stats
+var lineStats *logStats
+
+// number of level bands, Critical (0-9) to Debug (80-89)
+const bandCount = 9
+
+// band of a level: 0 for Critical, 1 for Error, ...; levels out of range go to the nearest band
+func bandOf(level tl.LogLevel) int {
+	return min(max(int(level)/10, 0), bandCount-1)
+}
+
+// histogram width in characters and rows; buckets get wider to fit the rows
+const (
+	histogramWidth = 50
+	histogramRows  = 120
+)
+
+// bucket widths tried for the histogram, the first one that fits histogramRows is used
+var histogramBuckets = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+/*
+logStats aggregates lines that pass the filters: counts per level band,
+per goroutine and per format string (tintlog keeps the raw format, so it
+works as a message template) and per minute for the rate histogram.
+*/
+type logStats struct {
+	top     int
+	total   int
+	bands   [bandCount]int
+	tids    map[int]int
+	formats map[string]int
+	// per minute (unix seconds of the minute start) -> lines per band
+	minutes map[int64]*[bandCount]int
+	first   time.Time
+	last    time.Time
+}
+
+func newLogStats(top int) *logStats {
+	return &logStats{
+		top:     top,
+		tids:    make(map[int]int),
+		formats: make(map[string]int),
+		minutes: make(map[int64]*[bandCount]int),
+	}
+}
+
+func (s *logStats) add(logLine tl.LogLine) {
+	band := bandOf(logLine.Level)
+	s.total++
+	s.bands[band]++
+	s.tids[logLine.TID]++
+	s.formats[logLine.Format]++
+
+	minute := logLine.Time.Truncate(time.Minute).Unix()
+	counts := s.minutes[minute]
+	if counts == nil {
+		counts = new([bandCount]int)
+		s.minutes[minute] = counts
+	}
+	counts[band]++
+
+	if s.first.IsZero() || logLine.Time.Before(s.first) {
+		s.first = logLine.Time
+	}
+	if logLine.Time.After(s.last) {
+		s.last = logLine.Time
+	}
+}
+
+// countEntry is a key with its count, for sorted top-N lists
+type countEntry[K comparable] struct {
+	Key   K   `json:"key"`
+	Count int `json:"count"`
+}
+
+// topCounts sorts counts by count (then key order given by less), keeping the first n
+func topCounts[K comparable](counts map[K]int, n int, less func(a, b K) bool) (top []countEntry[K], others int) {
+	for k, c := range counts {
+		top = append(top, countEntry[K]{k, c})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return less(top[i].Key, top[j].Key)
+	})
+	if n >= 0 && len(top) > n {
+		for _, e := range top[n:] {
+			others += e.Count
+		}
+		top = top[:n]
+	}
+	return top, others
+}
+
+// histogramBucket is one row of the rate histogram
+type histogramBucket struct {
+	Start  time.Time      `json:"start"`
+	Bands  [bandCount]int `json:"-"`
+	Count  int            `json:"count"`
+	Levels map[string]int `json:"levels,omitempty"`
+}
+
+// histogram re-buckets per-minute counts into at most histogramRows rows, empty rows included
+func (s *logStats) histogram() (buckets []histogramBucket, width time.Duration) {
+	if s.total == 0 {
+		return nil, time.Minute
+	}
+	// buckets are aligned to local midnight, like time-based log file rotation
+	start := func(t time.Time, d time.Duration) time.Time {
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		return midnight.Add(t.Sub(midnight) / d * d)
+	}
+	first, last := s.first.Local(), s.last.Local()
+	width = histogramBuckets[len(histogramBuckets)-1]
+	for _, d := range histogramBuckets {
+		if int(start(last, d).Sub(start(first, d))/d)+1 <= histogramRows {
+			width = d
+			break
+		}
+	}
+
+	from := start(first, width)
+	buckets = make([]histogramBucket, int(start(last, width).Sub(from)/width)+1)
+	for i := range buckets {
+		buckets[i].Start = from.Add(time.Duration(i) * width)
+	}
+	for minute, counts := range s.minutes {
+		i := int(start(time.Unix(minute, 0), width).Sub(from) / width)
+		i = min(max(i, 0), len(buckets)-1)
+		for band, c := range counts {
+			buckets[i].Bands[band] += c
+			buckets[i].Count += c
+		}
+	}
+	return buckets, width
+}
+
+// print the report as text, colored unless colored is false
+func (s *logStats) print(colored bool) {
+	apply := func(c palette.Colorizer, str string) string {
+		if !colored {
+			return str
+		}
+		return palette.Downsample(c.Apply(str), colorDepth)
+	}
+	heading := func(title string) {
+		fmt.Fprintf(stdout, "\n%s\n", apply(palette.GrayBrightBold, title))
+	}
+	percent := func(n int) string {
+		return fmt.Sprintf("%5.1f%%", 100*float64(n)/float64(max(s.total, 1)))
+	}
+
+	fmt.Fprintf(stdout, "%s %d\n", apply(palette.GrayBrightBold, "Lines:"), s.total)
+	if badLines.count > 0 {
+		fmt.Fprintf(stdout, "%s %d\n", apply(palette.Red, "Malformed:"), badLines.count)
+	}
+	if s.total == 0 {
+		return
+	}
+	fmt.Fprintf(stdout, "First: %s\nLast:  %s\nSpan:  %s\n",
+		apply(tl.Cfg.LogTimeColor, s.first.Format(tl.Cfg.TimeFormat)),
+		apply(tl.Cfg.LogTimeColor, s.last.Format(tl.Cfg.TimeFormat)),
+		s.last.Sub(s.first).Round(time.Second))
+
+	heading("Levels")
+	for band, n := range s.bands {
+		if n == 0 {
+			continue
+		}
+		level := tl.LogLevel(band * 10)
+		name := fmt.Sprintf("%-10s", level.String())
+		fmt.Fprintf(stdout, "  %s %8d %s\n", apply(tl.DefaultLevelColorizer(level), name), n, percent(n))
+	}
+
+	heading("Goroutines")
+	tids, others := topCounts(s.tids, s.top, func(a, b int) bool { return a < b })
+	for _, e := range tids {
+		tid := "none"
+		if e.Key > 0 {
+			tid = strconv.Itoa(e.Key)
+		}
+		fmt.Fprintf(stdout, "  %-10s %8d %s\n", tid, e.Count, percent(e.Count))
+	}
+	if others > 0 {
+		fmt.Fprintf(stdout, "  %-10s %8d %s\n", fmt.Sprintf("+%d more", len(s.tids)-len(tids)), others, percent(others))
+	}
+
+	heading(fmt.Sprintf("Top %d formats", min(len(s.formats), s.top)))
+	formats, _ := topCounts(s.formats, s.top, func(a, b string) bool { return a < b })
+	for _, e := range formats {
+		fmt.Fprintf(stdout, "  %8d %s  %s\n", e.Count, percent(e.Count), strconv.Quote(e.Key))
+	}
+
+	buckets, width := s.histogram()
+	heading("Lines per " + shortDuration(width))
+	peak := 0
+	for _, b := range buckets {
+		peak = max(peak, b.Count)
+	}
+	labelFormat := "15:04"
+	if s.first.Local().Format(time.DateOnly) != s.last.Local().Format(time.DateOnly) || width >= 24*time.Hour {
+		labelFormat = "Jan 02 15:04"
+	}
+	for _, b := range buckets {
+		fmt.Fprintf(stdout, "  %s %s %d\n", apply(tl.Cfg.LogTimeColor, b.Start.Format(labelFormat)), histogramBar(b.Bands, peak, apply), b.Count)
+	}
+}
+
+// histogramBar draws a bar scaled to peak, split into segments colored by level band
+func histogramBar(bands [bandCount]int, peak int, apply func(palette.Colorizer, string) string) string {
+	var sb strings.Builder
+	cumulative, drawn := 0, 0
+	for band, n := range bands {
+		if n == 0 {
+			continue
+		}
+		cumulative += n
+		// cumulative rounding keeps the total bar length right
+		end := (cumulative*histogramWidth + peak - 1) / max(peak, 1)
+		if end > drawn {
+			sb.WriteString(apply(tl.DefaultLevelColorizer(tl.LogLevel(band*10)), strings.Repeat("█", end-drawn)))
+			drawn = end
+		}
+	}
+	return sb.String() + strings.Repeat(" ", histogramWidth-drawn)
+}
+
+// shortDuration renders bucket widths like "minute", "5m" or "1d"
+func shortDuration(d time.Duration) string {
+	switch {
+	case d == time.Minute:
+		return "minute"
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// printJSON prints the report as one JSON object, for --output json
+func (s *logStats) printJSON() {
+	type levelCount struct {
+		Level string `json:"level"`
+		Count int    `json:"count"`
+	}
+	report := struct {
+		Lines     int                  `json:"lines"`
+		Malformed int                  `json:"malformed"`
+		First     *time.Time           `json:"first,omitempty"`
+		Last      *time.Time           `json:"last,omitempty"`
+		Levels    []levelCount         `json:"levels"`
+		TIDs      []countEntry[int]    `json:"tids"`
+		Formats   []countEntry[string] `json:"formats"`
+		Bucket    string               `json:"bucket"`
+		Histogram []histogramBucket    `json:"histogram"`
+	}{Lines: s.total, Malformed: badLines.count, Levels: []levelCount{}}
+	if s.total > 0 {
+		report.First, report.Last = &s.first, &s.last
+	}
+	for band, n := range s.bands {
+		if n > 0 {
+			report.Levels = append(report.Levels, levelCount{tl.LogLevel(band * 10).String(), n})
+		}
+	}
+	report.TIDs, _ = topCounts(s.tids, s.top, func(a, b int) bool { return a < b })
+	report.Formats, _ = topCounts(s.formats, s.top, func(a, b string) bool { return a < b })
+	var width time.Duration
+	report.Histogram, width = s.histogram()
+	report.Bucket = width.String()
+	for i, b := range report.Histogram {
+		for band, n := range b.Bands {
+			if n > 0 {
+				if report.Histogram[i].Levels == nil {
+					report.Histogram[i].Levels = make(map[string]int)
+				}
+				report.Histogram[i].Levels[tl.LogLevel(band*10).String()] = n
+			}
+		}
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	stdout.Write(append(b, '\n'))
+}
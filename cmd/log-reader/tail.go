@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+const readChunkSize = 64 * 1024
+
+// lineFunc handles one line; lineNo is its 1-based number in the file,
+// negative when counted from the end (--tail) and 0 when unknown
+type lineFunc func(lineNo int, line []byte) (err error, errMsg string)
+
+// eachLine streams non-empty lines of r to fn, one line in memory at a time
+func eachLine(r io.Reader, fn lineFunc) (err error, errMsg string) {
+	br := bufio.NewReaderSize(r, readChunkSize)
+	lineNo := 0
+	for {
+		line, readErr := br.ReadBytes('\n')
+		line = bytes.TrimRight(line, "\r\n")
+		lineNo++
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err, errMsg = fn(lineNo, line); err != nil {
+				return err, errMsg
+			}
+		}
+		if readErr == io.EOF {
+			return nil, ""
+		}
+		if readErr != nil {
+			return readErr, "Error while reading file"
+		}
+	}
+}
+
+/*
+lastLines returns the last n non-empty lines of the first size bytes of r.
+It reads backwards from size in chunks, so only those lines are kept in memory
+no matter how big the file is.
+*/
+func lastLines(r io.ReaderAt, size int64, n int) ([][]byte, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	var lines [][]byte
+	// bytes after the newest newline seen so far, a line that may continue further back
+	var carry []byte
+	pos := size
+	chunk := make([]byte, readChunkSize)
+	for pos > 0 && len(lines) < n {
+		readSize := int64(len(chunk))
+		if pos < readSize {
+			readSize = pos
+		}
+		pos -= readSize
+		if _, err := r.ReadAt(chunk[:readSize], pos); err != nil && err != io.EOF {
+			return nil, err
+		}
+		data := append(append([]byte(nil), chunk[:readSize]...), carry...)
+		for len(lines) < n {
+			nl := bytes.LastIndexByte(data, '\n')
+			if nl < 0 {
+				break
+			}
+			if line := bytes.TrimRight(data[nl+1:], "\r"); len(bytes.TrimSpace(line)) > 0 {
+				// copy so the chunk can be freed
+				lines = append(lines, append([]byte(nil), line...))
+			}
+			data = data[:nl]
+		}
+		carry = data
+	}
+	// the first line of the file has no newline before it
+	if pos == 0 && len(lines) < n {
+		if line := bytes.TrimRight(carry, "\r"); len(bytes.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	// collected newest first
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines, nil
+}
+
+// completeEnd returns the offset just after the last newline in the first size bytes of r,
+// so a line that is still being written can be left for later.
+func completeEnd(r io.ReaderAt, size int64) (int64, error) {
+	pos := size
+	chunk := make([]byte, readChunkSize)
+	for pos > 0 {
+		readSize := int64(len(chunk))
+		if pos < readSize {
+			readSize = pos
+		}
+		pos -= readSize
+		if _, err := r.ReadAt(chunk[:readSize], pos); err != nil && err != io.EOF {
+			return 0, err
+		}
+		if nl := bytes.LastIndexByte(chunk[:readSize], '\n'); nl >= 0 {
+			return pos + int64(nl) + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// lastLinesOf keeps the last n lines of a stream that can't be read backwards (gzip)
+func lastLinesOf(r io.Reader, n int) (lines [][]byte, err error, errMsg string) {
+	if n <= 0 {
+		return nil, nil, ""
+	}
+	ring := make([][]byte, 0, n)
+	next := 0
+	err, errMsg = eachLine(r, func(_ int, line []byte) (error, string) {
+		line = append([]byte(nil), line...)
+		if len(ring) < n {
+			ring = append(ring, line)
+		} else {
+			ring[next] = line
+			next = (next + 1) % n
+		}
+		return nil, ""
+	})
+	if err != nil {
+		return nil, err, errMsg
+	}
+	return append(ring[next:], ring[:next]...), nil, ""
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// terminal is the tty the tui draws on, only supported on unix systems
+type terminal struct {
+	tty    *os.File
+	resize chan os.Signal
+}
+
+func openTerminal() (*terminal, error) {
+	return nil, errors.New("the tui needs a unix terminal")
+}
+
+func (t *terminal) size() (rows, cols int, err error) {
+	return 0, 0, errors.New("the tui needs a unix terminal")
+}
+
+func (t *terminal) restore() {}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// terminal is the tty the tui draws on, in raw mode until restore
+type terminal struct {
+	tty *os.File
+	// the terminal state before raw mode, as printed by stty -g
+	saved string
+	// window size changes
+	resize chan os.Signal
+}
+
+// openTerminal switches the controlling terminal to raw mode with stty
+func openTerminal() (*terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		tty.Close()
+		return nil, err
+	}
+	if _, err = stty(tty, "raw", "-echo"); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	t := &terminal{tty: tty, saved: saved, resize: make(chan os.Signal, 1)}
+	signal.Notify(t.resize, syscall.SIGWINCH)
+	return t, nil
+}
+
+// size returns the terminal size in rows and columns
+func (t *terminal) size() (rows, cols int, err error) {
+	out, err := stty(t.tty, "size")
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err = fmt.Sscan(out, &rows, &cols); err != nil {
+		return 0, 0, err
+	}
+	return rows, cols, nil
+}
+
+// restore puts the terminal back into the state it was in before openTerminal
+func (t *terminal) restore() {
+	signal.Stop(t.resize)
+	_, _ = stty(t.tty, t.saved)
+	t.tty.Close()
+}
+
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tl "github.com/tuumbleweed/tintlog/logger"
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// tuiLine is a line loaded for the tui, with its message rendered once
+type tuiLine struct {
+	logLine  tl.LogLine
+	source   string
+	plain    string
+	argSpans []span
+}
+
+// lines collected for the tui instead of printing, nil unless running `log-reader tui`
+var tuiLines *[]tuiLine
+
+const tuiHelp = "j/k ↑/↓ scroll, PgUp/PgDn, g/G top/bottom, ←/→ level ±1, [/] level band, t goroutine, " +
+	"Enter expand, / search, n/N next/prev, : jump to time, q quit"
+
+/*
+tui is an interactive browser of loaded lines.
+
+Lines stay in file order; the level slider (--level at start) and the
+goroutine toggle only change which of them are visible.
+*/
+type tui struct {
+	term *terminal
+	out  *bufio.Writer
+
+	lines   []tuiLine
+	visible []int // indexes of lines that pass the level and goroutine filters
+
+	rows, cols int
+	cursor     int // into visible
+	top        int // first visible line on screen
+
+	maxLevel tl.LogLevel
+	tid      int          // only lines of this goroutine when > 0
+	expanded map[int]bool // by index into lines
+
+	search *regexp.Regexp
+	regex  bool // search input is a regular expression (--regex)
+
+	// prompt is "/" or ":" while reading a search or a time, "" otherwise
+	prompt string
+	input  []rune
+	// shown in the status bar until the next key
+	message string
+
+	depth palette.ColorDepth
+}
+
+// runTUI browses lines until the user quits
+func runTUI(lines []tuiLine, maxLevel tl.LogLevel, regex bool) (err error, errMsg string) {
+	term, err := openTerminal()
+	if err != nil {
+		return err, "Unable to open the terminal for the tui"
+	}
+	defer term.restore()
+
+	t := &tui{
+		term:     term,
+		out:      bufio.NewWriter(term.tty),
+		lines:    lines,
+		maxLevel: maxLevel,
+		expanded: make(map[int]bool),
+		regex:    regex,
+		depth:    palette.DetectColorDepth(),
+	}
+	if !tl.ShouldColor(term.tty, tl.Cfg.Color) {
+		t.depth = palette.DepthNone
+	}
+	if len(lines) == 0 {
+		t.message = "no lines match the filters"
+	}
+
+	// alternate screen, hidden cursor
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		t.out.WriteString("\x1b[?25h\x1b[?1049l")
+		t.out.Flush()
+	}()
+
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := term.tty.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+
+	t.resize()
+	t.refilter()
+	for {
+		t.draw()
+		select {
+		case b, ok := <-keys:
+			if !ok {
+				return nil, ""
+			}
+			for _, key := range parseKeys(b) {
+				if !t.handleKey(key) {
+					return nil, ""
+				}
+			}
+		case <-term.resize:
+			t.resize()
+		}
+	}
+}
+
+func (t *tui) resize() {
+	rows, cols, err := t.term.size()
+	if err != nil || rows < 2 || cols < 10 {
+		rows, cols = 24, 80
+	}
+	t.rows, t.cols = rows, cols
+}
+
+// refilter recomputes visible lines, keeping the cursor on the same line or the closest one before it
+func (t *tui) refilter() {
+	current := -1
+	if t.cursor < len(t.visible) {
+		current = t.visible[t.cursor]
+	}
+	t.visible = t.visible[:0]
+	t.cursor = 0
+	for i, line := range t.lines {
+		if line.logLine.Level > t.maxLevel {
+			continue
+		}
+		if t.tid > 0 && line.logLine.TID != t.tid {
+			continue
+		}
+		if i <= current {
+			t.cursor = len(t.visible)
+		}
+		t.visible = append(t.visible, i)
+	}
+}
+
+// handleKey applies a key, returns false to quit
+func (t *tui) handleKey(key string) bool {
+	if t.prompt != "" {
+		t.promptKey(key)
+		return true
+	}
+	t.message = ""
+	page := max(t.rows-2, 1)
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "j", "down":
+		t.move(1)
+	case "k", "up":
+		t.move(-1)
+	case "pgdn", " ", "ctrl-f", "ctrl-d":
+		t.move(page)
+	case "pgup", "b", "ctrl-b", "ctrl-u":
+		t.move(-page)
+	case "g", "home":
+		t.move(-len(t.visible))
+	case "G", "end":
+		t.move(len(t.visible))
+	case "right", "+", "=":
+		t.setLevel(t.maxLevel + 1)
+	case "left", "-":
+		t.setLevel(t.maxLevel - 1)
+	case "]":
+		t.setLevel(tl.StepBand(t.maxLevel, 1))
+	case "[":
+		t.setLevel(tl.StepBand(t.maxLevel, -1))
+	case "t":
+		t.toggleTid()
+	case "enter", "tab", "e":
+		if line, ok := t.current(); ok {
+			t.expanded[line] = !t.expanded[line]
+		}
+	case "/", ":":
+		t.prompt, t.input = key, nil
+	case "n":
+		t.findNext(1, false)
+	case "N":
+		t.findNext(-1, false)
+	case "?", "h":
+		t.message = tuiHelp
+	}
+	return true
+}
+
+// promptKey edits the search or time prompt
+func (t *tui) promptKey(key string) {
+	switch key {
+	case "esc", "ctrl-c":
+		t.prompt = ""
+	case "backspace":
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	case "ctrl-u":
+		t.input = nil
+	case "enter":
+		prompt, input := t.prompt, string(t.input)
+		t.prompt = ""
+		if prompt == "/" {
+			t.setSearch(input)
+		} else {
+			t.jumpToTime(input)
+		}
+	default:
+		if utf8.RuneCountInString(key) == 1 {
+			t.input = append(t.input, []rune(key)...)
+		}
+	}
+}
+
+func (t *tui) current() (line int, ok bool) {
+	if t.cursor < len(t.visible) {
+		return t.visible[t.cursor], true
+	}
+	return 0, false
+}
+
+func (t *tui) move(delta int) {
+	t.cursor = min(max(t.cursor+delta, 0), max(len(t.visible)-1, 0))
+}
+
+func (t *tui) setLevel(level tl.LogLevel) {
+	t.maxLevel = min(max(level, tl.Critical), 99)
+	t.refilter()
+}
+
+// toggleTid shows only the goroutine of the current line, or every goroutine again
+func (t *tui) toggleTid() {
+	if t.tid > 0 {
+		t.tid = 0
+		t.refilter()
+		return
+	}
+	line, ok := t.current()
+	if !ok {
+		return
+	}
+	if t.lines[line].logLine.TID <= 0 {
+		t.message = "this line has no goroutine id, log with use_tid on"
+		return
+	}
+	t.tid = t.lines[line].logLine.TID
+	t.refilter()
+}
+
+func (t *tui) setSearch(input string) {
+	re, err := compileGrep(input, t.regex, true)
+	if err != nil {
+		t.message = "bad search: " + err.Error()
+		return
+	}
+	t.search = re
+	if re != nil {
+		t.findNext(1, true)
+	}
+}
+
+// findNext moves the cursor to the next (dir 1) or previous (dir -1) match of the search
+func (t *tui) findNext(dir int, includeCurrent bool) {
+	if t.search == nil {
+		t.message = "no search, press / to search"
+		return
+	}
+	start := t.cursor + dir
+	if includeCurrent {
+		start = t.cursor
+	}
+	for i := start; i >= 0 && i < len(t.visible); i += dir {
+		if t.search.MatchString(t.lines[t.visible[i]].plain) {
+			t.cursor = i
+			return
+		}
+	}
+	t.message = "no more matches"
+}
+
+/*
+jumpToTime moves to the first visible line at or after a time given in
+tl.Cfg.TimeFormat, RFC 3339, or as a clock time ("15:04" or "15:04:05")
+on the day of the current line.
+*/
+func (t *tui) jumpToTime(input string) {
+	input = strings.TrimSpace(input)
+	if input == "" || len(t.visible) == 0 {
+		return
+	}
+	ref := t.lines[t.visible[t.cursor]].logLine.Time.Local()
+	var target time.Time
+	var err error
+	for _, layout := range []string{tl.Cfg.TimeFormat, time.RFC3339, time.DateTime, "15:04:05", "15:04"} {
+		if target, err = time.ParseInLocation(layout, input, time.Local); err == nil {
+			if layout == "15:04:05" || layout == "15:04" {
+				target = time.Date(ref.Year(), ref.Month(), ref.Day(), target.Hour(), target.Minute(), target.Second(), 0, time.Local)
+			}
+			break
+		}
+	}
+	if err != nil {
+		t.message = fmt.Sprintf("can't parse time %q, use %q or 15:04", input, tl.Cfg.TimeFormat)
+		return
+	}
+	for i, line := range t.visible {
+		if !t.lines[line].logLine.Time.Before(target) {
+			t.cursor = i
+			return
+		}
+	}
+	t.cursor = len(t.visible) - 1
+	t.message = "no lines after " + input
+}
+
+// lineRows renders a line as screen rows: the line itself, and its details if expanded
+func (t *tui) lineRows(index int) []string {
+	line := t.lines[index]
+	// one row per line: newlines become spaces, keeping span offsets valid
+	flat := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, line.plain)
+	msg := colorMessage(flat, line.argSpans, grepSpans(t.search, flat), pickColorizer(line.logLine.Color), highlightColor)
+	source := line.source
+	rows := []string{formatTextLine(line.logLine, msg, source, true)}
+	if !t.expanded[index] {
+		return rows
+	}
+
+	detail := func(name string, v any) {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			b = []byte(fmt.Sprint(v))
+		}
+		for i, row := range strings.Split(string(b), "\n") {
+			label := strings.Repeat(" ", len(name)+2)
+			if i == 0 {
+				label = name + ": "
+			}
+			rows = append(rows, "    "+palette.GrayDim.Apply(label)+row)
+		}
+	}
+	detail("args", line.logLine.Args)
+	if len(line.logLine.Fields) > 0 {
+		detail("fields", line.logLine.Fields)
+	}
+	if line.logLine.Caller != nil {
+		detail("caller", line.logLine.Caller.String())
+	}
+	detail("source", source)
+	for _, row := range strings.Split(strings.TrimRight(line.logLine.Stack, "\n"), "\n") {
+		if row != "" {
+			rows = append(rows, "    "+palette.GrayDim.Apply(row))
+		}
+	}
+	return rows
+}
+
+// scroll keeps the cursor line (with its details) on screen
+func (t *tui) scroll(height int) {
+	if t.cursor < t.top {
+		t.top = t.cursor
+		return
+	}
+	// walk up from the cursor while the lines still fit
+	used := 0
+	for i := t.cursor; i >= t.top; i-- {
+		used += len(t.lineRows(t.visible[i]))
+		if used > height {
+			t.top = min(i+1, t.cursor)
+			return
+		}
+	}
+}
+
+func (t *tui) draw() {
+	height := t.rows - 1
+	t.scroll(height)
+
+	row := 1
+	for i := t.top; i < len(t.visible) && row <= height; i++ {
+		gutter := " "
+		if i == t.cursor {
+			gutter = palette.YellowBold.Apply("▌")
+		}
+		for _, text := range t.lineRows(t.visible[i]) {
+			if row > height {
+				break
+			}
+			t.drawRow(row, gutter+truncateANSI(text, t.cols-1))
+			gutter = " "
+			row++
+		}
+	}
+	for ; row <= height; row++ {
+		t.drawRow(row, "")
+	}
+	t.drawRow(t.rows, truncateANSI(t.status(), t.cols))
+	t.out.Flush()
+}
+
+func (t *tui) drawRow(row int, text string) {
+	fmt.Fprintf(t.out, "\x1b[%d;1H%s\x1b[0m\x1b[K", row, palette.Downsample(text, t.depth))
+}
+
+// status is the bottom row: position, level slider, goroutine filter and search, or the prompt
+func (t *tui) status() string {
+	if t.prompt != "" {
+		label := "search: "
+		if t.prompt == ":" {
+			label = "time: "
+		}
+		return palette.YellowBold.Apply(label) + string(t.input) + "█"
+	}
+
+	position := fmt.Sprintf("%d/%d", min(t.cursor+1, len(t.visible)), len(t.visible))
+	if len(t.visible) != len(t.lines) {
+		position += fmt.Sprintf(" of %d", len(t.lines))
+	}
+	parts := []string{position, "level ≤ " + levelSlider(t.maxLevel)}
+	if t.tid > 0 {
+		parts = append(parts, fmt.Sprintf("tid %d", t.tid))
+	}
+	if t.search != nil {
+		parts = append(parts, "/"+highlightColor.Apply(strings.TrimPrefix(t.search.String(), "(?i)")))
+	}
+	if t.message != "" {
+		parts = append(parts, palette.Yellow.Apply(t.message))
+	} else {
+		parts = append(parts, palette.GrayDim.Apply("? help"))
+	}
+	return strings.Join(parts, palette.GrayDim.Apply(" │ "))
+}
+
+// levelSlider draws the 0-99 scale with the part up to level filled in its band color
+func levelSlider(level tl.LogLevel) string {
+	const width = 20
+	filled := (int(level) + 1) * width / 100
+	colorize := tl.DefaultLevelColorizer(level)
+	return fmt.Sprintf("%s %2d ", colorize.Apply(fmt.Sprintf("%-10s", level.String())), int(level)) +
+		colorize.Apply(strings.Repeat("█", filled)) + palette.GrayDim.Apply(strings.Repeat("░", width-filled))
+}
+
+// truncateANSI cuts s to width printable runes, keeping escape sequences intact
+func truncateANSI(s string, width int) string {
+	var b strings.Builder
+	visible := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			j := i + 1
+			if j < len(s) && s[j] == '[' {
+				// parameters and intermediates, then the final byte
+				j++
+				for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+					j++
+				}
+				j++
+			}
+			j = min(j, len(s))
+			b.WriteString(s[i:j])
+			i = j
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if visible == width {
+			break
+		}
+		if r < 0x20 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		visible++
+		i += size
+	}
+	return b.String()
+}
+
+// parseKeys splits raw terminal input into key names like "up", "enter", "ctrl-c" or the typed character
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			if len(b) > 2 && (b[1] == '[' || b[1] == 'O') {
+				// CSI or SS3: digits and ';', then the final byte
+				j := 2
+				for j < len(b) && (b[j] >= '0' && b[j] <= '9' || b[j] == ';') {
+					j++
+				}
+				if j < len(b) {
+					if key := csiKey(string(b[2:j]), b[j]); key != "" {
+						keys = append(keys, key)
+					}
+					b = b[j+1:]
+					continue
+				}
+			}
+			keys = append(keys, "esc")
+			b = b[1:]
+			continue
+		}
+		switch b[0] {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 127, 8:
+			keys = append(keys, "backspace")
+		case '\t':
+			keys = append(keys, "tab")
+		case 2:
+			keys = append(keys, "ctrl-b")
+		case 3:
+			keys = append(keys, "ctrl-c")
+		case 4:
+			keys = append(keys, "ctrl-d")
+		case 6:
+			keys = append(keys, "ctrl-f")
+		case 21:
+			keys = append(keys, "ctrl-u")
+		default:
+			r, size := utf8.DecodeRune(b)
+			if r >= 0x20 {
+				keys = append(keys, string(r))
+			}
+			b = b[size:]
+			continue
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+func csiKey(params string, final byte) string {
+	switch final {
+	case 'A':
+		return "up"
+	case 'B':
+		return "down"
+	case 'C':
+		return "right"
+	case 'D':
+		return "left"
+	case 'H':
+		return "home"
+	case 'F':
+		return "end"
+	case '~':
+		switch params {
+		case "1", "7":
+			return "home"
+		case "4", "8":
+			return "end"
+		case "5":
+			return "pgup"
+		case "6":
+			return "pgdn"
+		}
+	}
+	return ""
+}
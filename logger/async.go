@@ -0,0 +1,256 @@
+package tl
+
+import (
+	"bufio"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// What to do with a new line when the async file queue is full, see Config.LogFileDropPolicy.
+const (
+	// wait until the writer makes room, nothing is lost
+	DropPolicyBlock = "block"
+	// drop the line being logged
+	DropPolicyDropNewest = "drop_newest"
+	// drop the least important line (highest LogLevel), queued or new
+	DropPolicyDropLowestLevel = "drop_lowest_level"
+)
+
+const asyncBufferSize = 64 * 1024
+
+// queuedLine is a marshaled JSONL record, or a flush marker if flushed != nil
+type queuedLine struct {
+	b       []byte
+	level   LogLevel
+	flushed chan struct{}
+}
+
+// asyncQueue is a bounded queue between loggers and the background file writer
+type asyncQueue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []queuedLine
+	lines    int // items that are lines, flush markers don't count against size
+	size     int
+	policy   string
+	dropped  uint64
+	closed   bool
+	done     chan struct{} // closed when the writer goroutine exits
+}
+
+func newAsyncQueue(size int, policy string) *asyncQueue {
+	if size <= 0 {
+		size = 1
+	}
+	q := &asyncQueue{size: size, policy: policy, done: make(chan struct{})}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues a line according to the drop policy. Returns false if the queue is closed.
+func (q *asyncQueue) push(line queuedLine) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	if line.flushed == nil {
+		for q.lines >= q.size {
+			switch q.policy {
+			case DropPolicyDropNewest:
+				q.dropped++
+				return true
+			case DropPolicyDropLowestLevel:
+				if !q.dropLowestLocked(line.level) {
+					q.dropped++
+					return true
+				}
+			default: // DropPolicyBlock
+				q.notFull.Wait()
+				if q.closed {
+					return false
+				}
+			}
+		}
+		q.lines++
+	}
+	q.items = append(q.items, line)
+	q.notEmpty.Signal()
+	return true
+}
+
+// dropLowestLocked removes the least important queued line if it's less important
+// than a new line with the given level. Returns false if the new line should be dropped instead.
+func (q *asyncQueue) dropLowestLocked(level LogLevel) bool {
+	idx := -1
+	for i, it := range q.items {
+		if it.flushed == nil && (idx < 0 || it.level > q.items[idx].level) {
+			idx = i
+		}
+	}
+	if idx < 0 || q.items[idx].level <= level {
+		return false
+	}
+	q.items = append(q.items[:idx], q.items[idx+1:]...)
+	q.lines--
+	q.dropped++
+	return true
+}
+
+// take waits for queued lines and returns all of them, with the number of lines
+// dropped since the last call. closed is true once the queue is closed and drained.
+func (q *asyncQueue) take() (batch []queuedLine, dropped uint64, closed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && q.dropped == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	batch, dropped = q.items, q.dropped
+	q.items, q.lines, q.dropped = nil, 0, 0
+	q.notFull.Broadcast()
+	return batch, dropped, q.closed
+}
+
+func (q *asyncQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.mu.Unlock()
+}
+
+// runWriter writes queued lines through a bufio.Writer until the queue is closed.
+// The buffer is flushed after every batch, so lines reach the file as soon as the writer is idle.
+func (s *fileSink) runWriter(q *asyncQueue) {
+	defer close(q.done)
+	for {
+		batch, dropped, closed := q.take()
+		s.mu.Lock()
+		if dropped > 0 {
+			s.writeLocked(droppedRecord(dropped))
+		}
+		for _, it := range batch {
+			if it.flushed != nil {
+				s.flushLocked()
+				close(it.flushed)
+				continue
+			}
+			s.writeLocked(it.b)
+		}
+		s.flushLocked()
+		s.mu.Unlock()
+		if closed {
+			return
+		}
+	}
+}
+
+// startAsyncLocked switches the sink to async mode once. Caller holds s.mu.
+func (s *fileSink) startAsyncLocked(size int, policy string) {
+	if s.async.Load() != nil {
+		return
+	}
+	q := newAsyncQueue(size, policy)
+	s.bw = bufio.NewWriterSize(s.file, asyncBufferSize)
+	s.async.Store(q)
+	go s.runWriter(q)
+}
+
+// droppedRecord reports lines dropped by a full queue in the file itself
+func droppedRecord(n uint64) []byte {
+	b, _ := json.Marshal(LogLine{
+		Time:   time.Now(),
+		Level:  Warning,
+		Color:  palette.YellowBold.Name,
+		Format: "%s %s log lines, log file queue was full",
+		Args:   []any{"Dropped", strconv.FormatUint(n, 10)},
+	})
+	return append(b, '\n')
+}
+
+// flushLocked writes buffered lines to the file. Caller holds s.mu.
+func (s *fileSink) flushLocked() {
+	if s.bw != nil {
+		_ = s.bw.Flush()
+	}
+}
+
+// flush waits until every line queued so far is written, then syncs the file to disk.
+func (s *fileSink) flush() error {
+	if q := s.async.Load(); q != nil {
+		done := make(chan struct{})
+		if q.push(queuedLine{flushed: done}) {
+			<-done
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushLocked()
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Sync()
+}
+
+// close drains the queue, stops the writer, waits for background cleanup and closes the file.
+func (s *fileSink) close() error {
+	if q := s.async.Load(); q != nil {
+		q.close()
+		<-q.done
+		s.async.Store(nil)
+	}
+
+	s.mu.Lock()
+	s.flushLocked()
+	s.bw = nil
+	var err error
+	if s.file != nil {
+		err = s.file.Close()
+	}
+	s.file = nil
+	s.publishLocked()
+	s.opened.Store(false)
+	s.mu.Unlock()
+
+	// cleanups still running must see the closed file as current, or they could compress it
+	s.cleanupWG.Wait()
+	s.mu.Lock()
+	if s.file == nil { // not reopened meanwhile
+		s.path = ""
+		s.publishLocked()
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// Flush waits until every line logged so far is written to the log file.
+// Lines from all loggers sharing the file are flushed, after a report of lines
+// suppressed by sampling (see SamplingConfig).
+func (l *Logger) Flush() error {
+	l.sampler.report()
+	return l.sink.flush()
+}
+
+// Close flushes and closes the log file and stops sampling (see SamplingConfig).
+// Lines logged afterwards are only printed.
+func (l *Logger) Close() error {
+	l.sampler.close()
+	return l.sink.close()
+}
+
+// Flush flushes the log file of the default logger, see (*Logger).Flush.
+func Flush() error {
+	return std.Flush()
+}
+
+// Close closes the log file of the default logger, see (*Logger).Close.
+// Call it (or Flush) before exiting when async_log_file is on.
+func Close() error {
+	return std.Close()
+}
@@ -0,0 +1,19 @@
+package tl
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// args are read back as strings, so the record must not use verbs like %d
+func TestDroppedRecordRenders(t *testing.T) {
+	var line LogLine
+	if err := json.Unmarshal(droppedRecord(297), &line); err != nil {
+		t.Fatal(err)
+	}
+	want := "Dropped 297 log lines, log file queue was full"
+	if got := fmt.Sprintf(line.Format, line.Args...); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package tl
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Caller is the call site that produced a log line.
+type Caller struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"function,omitempty"`
+}
+
+// String returns "/full/path/file.go:123".
+func (c Caller) String() string {
+	return c.File + ":" + strconv.Itoa(c.Line)
+}
+
+// Short returns "pkg/file.go:123", the file with its parent directory only.
+func (c Caller) Short() string {
+	dir := filepath.Base(filepath.Dir(c.File))
+	return dir + "/" + filepath.Base(c.File) + ":" + strconv.Itoa(c.Line)
+}
+
+// function name prefix of this package, like "github.com/tuumbleweed/tintlog/logger."
+var loggerPkgPrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	return name[:slash+1+dot+1]
+}()
+
+/*
+findCaller returns the first frame outside this package, then skips `skip` more frames.
+Wrappers in this package (Log, LogJSON, LogRewrite, the slog handler, ...) are
+skipped automatically; helpers in user code use WithCallerSkip.
+*/
+func findCaller(skip int) *Caller {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, loggerPkgPrefix) {
+			if skip == 0 {
+				return &Caller{File: frame.File, Line: frame.Line, Function: frame.Function}
+			}
+			skip--
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// callerFromPC resolves a program counter, like slog.Record.PC
+func callerFromPC(pc uintptr) *Caller {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.File == "" {
+		return nil
+	}
+	return &Caller{File: frame.File, Line: frame.Line, Function: frame.Function}
+}
+
+/*
+WithCallerSkip returns a logger that reports the caller `skip` frames above
+the first frame outside this package. Use it in your own logging helpers:
+
+	var helperLog = tl.Default().WithCallerSkip(1)
+
+	func logRequest(r *http.Request) {
+		helperLog.Log(tl.Info, palette.Cyan, "%s %s", r.Method, r.URL) // reports logRequest's caller
+	}
+*/
+func (l *Logger) WithCallerSkip(skip int) *Logger {
+	derived := *l
+	derived.callerSkip += skip
+	return &derived
+}
+
+// WithCallerSkip returns a derived default logger, see (*Logger).WithCallerSkip.
+func WithCallerSkip(skip int) *Logger {
+	return std.WithCallerSkip(skip)
+}
@@ -0,0 +1,115 @@
+package tl
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// Values for Config.Color.
+const (
+	// color only when the output is a terminal, honoring NO_COLOR, FORCE_COLOR and CLICOLOR_FORCE
+	ColorAuto = "auto"
+	// always print ANSI colors
+	ColorAlways = "always"
+	// never print ANSI colors
+	ColorNever = "never"
+)
+
+// isTerminal reports whether w is a character device, like a terminal on stderr.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// envForcesColor reports whether FORCE_COLOR or CLICOLOR_FORCE ask for color.
+func envForcesColor() bool {
+	for _, name := range []string{"FORCE_COLOR", "CLICOLOR_FORCE"} {
+		v := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+		if v != "" && v != "0" && v != "false" {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ShouldColor decides whether to print ANSI colors to w for a Config.Color mode.
+
+In auto mode (or any unknown value):
+  - FORCE_COLOR or CLICOLOR_FORCE set (and not "0"/"false") turns color on
+  - NO_COLOR set to anything non-empty, or TERM=dumb, turns color off
+  - otherwise color is on only if w is a terminal
+*/
+func ShouldColor(w io.Writer, mode string) bool {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if envForcesColor() {
+		return true
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// depthCache is a colorDepth result and what it was computed from
+type depthCache struct {
+	valid      bool
+	w          io.Writer
+	color      string
+	colorDepth string
+	depth      palette.ColorDepth
+}
+
+/*
+colorDepth returns the color depth this logger prints with:
+DepthNone when colors are off (see ShouldColor), otherwise Cfg.ColorDepth
+("auto" detects it from COLORTERM and TERM, unknown values mean truecolor).
+
+Checking the terminal and the environment takes syscalls, so the result is
+cached until the output (SetOutput) or the color config (InitializeConfig) changes.
+*/
+func (l *Logger) colorDepth() palette.ColorDepth {
+	o := l.out
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	c := &o.depth
+	if c.valid && sameWriter(c.w, *o.w) && c.color == l.Cfg.Color && c.colorDepth == l.Cfg.ColorDepth {
+		return c.depth
+	}
+	depth := palette.DepthNone
+	if ShouldColor(*o.w, l.Cfg.Color) {
+		depth, _ = palette.ParseColorDepth(l.Cfg.ColorDepth)
+	}
+	*c = depthCache{valid: true, w: *o.w, color: l.Cfg.Color, colorDepth: l.Cfg.ColorDepth, depth: depth}
+	return depth
+}
+
+// sameWriter compares writers that are pointers (like *os.File); any other writer
+// is never the same, it could panic on == and its decision is just not cached
+func sameWriter(a, b io.Writer) bool {
+	if a == nil || reflect.TypeOf(a).Kind() != reflect.Pointer {
+		return false
+	}
+	return a == b
+}
+
+// stripColor keeps the colorizer name (saved to JSONL) but drops its ANSI function
+func stripColor(c palette.Colorizer) palette.Colorizer {
+	return palette.Colorizer{Name: c.Name}
+}
@@ -0,0 +1,95 @@
+package tl
+
+import (
+	"path"
+	"strings"
+)
+
+/*
+Component returns a logger for a named part of the program, like "db" or "http".
+
+Its lines are printed by the level configured for it in Cfg.ComponentLevels
+(the logger level if no pattern matches, see SetComponentLevel to change it at runtime) and saved with a "component" field in JSONL.
+Calling Component on a component logger nests the names: "db" then "pool" gives "db.pool".
+The returned logger shares config, output and log file with l.
+*/
+func (l *Logger) Component(name string) *Logger {
+	derived := *l
+	if l.component != "" {
+		name = l.component + "." + name
+	}
+	derived.component = name
+	return &derived
+}
+
+// ComponentName returns the component of this logger, "" if it isn't a component logger.
+func (l *Logger) ComponentName() string {
+	return l.component
+}
+
+// Component returns a component logger derived from the default logger, see (*Logger).Component.
+func Component(name string) *Logger {
+	return std.Component(name)
+}
+
+/*
+MatchComponent reports whether a Cfg.ComponentLevels pattern matches a component name.
+
+A pattern matches its own name and every component nested in it ("db" matches
+"db" and "db.pool") and can use path.Match wildcards: "http.*", "*.cache" or "*".
+*/
+func MatchComponent(pattern, name string) bool {
+	if pattern == name || strings.HasPrefix(name, pattern+".") {
+		return true
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+/*
+ComponentLevel returns the level for a component from a pattern -> level map,
+or def if no pattern matches.
+
+The most specific pattern wins: an exact name first, then the longest pattern,
+so {"db": 40, "db.pool": 73} gives db.pool 73 and db.query 40.
+*/
+func ComponentLevel(levels map[string]LogLevel, name string, def LogLevel) LogLevel {
+	best, found := "", false
+	for pattern := range levels {
+		if !MatchComponent(pattern, name) {
+			continue
+		}
+		if !found || moreSpecific(pattern, best, name) {
+			best, found = pattern, true
+		}
+	}
+	if !found {
+		return def
+	}
+	return levels[best]
+}
+
+// moreSpecific reports whether pattern a is a better match for name than pattern b
+func moreSpecific(a, b, name string) bool {
+	if (a == name) != (b == name) {
+		return a == name
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	// map order is random, keep the result stable
+	return a < b
+}
+
+// level returns the threshold lines of this logger are printed by, see levelState
+func (l *Logger) level() LogLevel {
+	level := l.Level()
+	if l.component == "" {
+		return level
+	}
+	components := *l.levels.components.Load()
+	if len(components) == 0 {
+		return level
+	}
+	return ComponentLevel(components, l.component, level)
+}
@@ -1,74 +1,146 @@
 package tl
 
 import (
-	"os"
-
 	"github.com/tuumbleweed/tintlog/palette"
 )
 
-// user config set through a config file (logger section)
+// user config set through a config file (logger section), see LoadConfig
 type Config struct {
 	// log level to print to stderr, don't print any message with log level below this one
 	// for example by setting log level to Info5 (int value 55) every message
 	// with log level value bigger than 55, for example Verbose3 (int value 73)
 	// will not be printed to stderr
 	// lines are always saved to file if file is enabled (to look at log lines in detail)
+	// change it at runtime with SetLevel, HandleLevelSignals or LevelHandler (not by
+	// writing this field after InitializeConfig), they keep this field up to date
+	// in JSON it's a name like "Info5" or "info+5" or a number, see ParseLogLevel
+	// 0 (Critical) means the default when set in code, use SetLevel(Critical) after
+	// InitializeConfig for that; from a config file or TINTLOG_LEVEL it's kept
 	LogLevel LogLevel `json:"log_level,omitempty"`
+	// levels of component loggers (see Component) by name or pattern, for example
+	// {"db": "Notice", "http.*": "Verbose3"}. The most specific match wins, LogLevel is used if none matches
+	ComponentLevels map[string]LogLevel `json:"component_levels,omitempty"`
 	// specify a log directory if you want to duplicate all logs into a file by default
 	// in your programs provide a way to override this with --log-dir flag
 	LogDir string `json:"log_dir,omitempty"`
 	// if we want to print goroutine id with each log message
 	UseTid *bool `json:"use_tid,omitempty"`
+	// if we want to print the caller (file:line) with each log message and save it to file
+	UseCaller *bool `json:"use_caller,omitempty"`
+	// the caller is printed as pkg/file.go:123, set this to print the full file path instead
+	FullCallerPath *bool `json:"full_caller_path,omitempty"`
+	// when to print ANSI colors: "auto" (only to a terminal, honoring NO_COLOR,
+	// FORCE_COLOR and CLICOLOR_FORCE), "always" or "never"
+	Color string `json:"color,omitempty"`
+	// colors a terminal can show: "auto" (from COLORTERM and TERM), "truecolor", "256", "16" or "none".
+	// Truecolor palette colors are mapped to the nearest 256 or 16 color
+	ColorDepth string `json:"color_depth,omitempty"`
 	// time format to use
 	TimeFormat string `json:"time_format,omitempty"`
 	// log file format
 	LogFileFormat string `json:"log_file_format,omitempty"`
+	// start a new log file once the current one would grow past this many bytes, 0 to disable
+	LogFileMaxSize int64 `json:"log_file_max_size,omitempty"`
+	// start a new log file on a wall-clock interval: "hourly", "daily" or a duration like "15m".
+	// Intervals that divide a day are aligned to local midnight. Empty to disable.
+	// Can be combined with LogFileMaxSize, whichever comes first rotates the file.
+	LogFileRotateEvery string `json:"log_file_rotate_every,omitempty"`
+	// keep at most this many rotated log files in LogDir (not counting the current one), 0 to keep all
+	LogFileMaxCount int `json:"log_file_max_count,omitempty"`
+	// remove rotated log files older than this: a duration like "72h" or days like "7d". Empty to keep all
+	LogFileMaxAge string `json:"log_file_max_age,omitempty"`
+	// gzip rotated log files into .jsonl.gz in the background
+	CompressLogFiles *bool `json:"compress_log_files,omitempty"`
+	// write the log file from a background goroutine through a buffer.
+	// Call tl.Flush or tl.Close before exiting so queued lines are not lost
+	AsyncLogFile *bool `json:"async_log_file,omitempty"`
+	// how many lines the async queue holds before LogFileDropPolicy kicks in
+	LogFileQueueSize int `json:"log_file_queue_size,omitempty"`
+	// what to do when the async queue is full: "block", "drop_newest" or "drop_lowest_level".
+	// Dropped lines are counted and reported in the log file
+	LogFileDropPolicy string `json:"log_file_drop_policy,omitempty"`
+	// limit how many lines a hot call site can log, see SamplingConfig. Unset to log every line
+	Sampling *SamplingConfig `json:"sampling,omitempty"`
+	// exit code used by Fatal
+	ExitCode int `json:"exit_code,omitempty"`
 
 	// colorizer for the timestamp. Not JSON-serializable; runtime-only.
 	LogTimeColor palette.Colorizer `json:"-"`
+	// colorizer for the caller. Not JSON-serializable; runtime-only.
+	LogCallerColor palette.Colorizer `json:"-"`
+
+	// JSON keys given in the config file or environment (see LoadConfig), so that
+	// zero values like log_level Critical (0) are kept instead of taken for missing ones
+	set map[string]bool `json:"-"`
 }
 
 var Cfg Config = defaultConfig() // this one we use to access config values from anywhere
 
 func defaultConfig() Config {
 	useTid := false
+	compressLogFiles := false
+	asyncLogFile := false
+	useCaller := false
+	fullCallerPath := false
 	return Config{
 		LogLevel:           99,
 		LogDir:             "",
 		UseTid:             &useTid,
+		UseCaller:          &useCaller,
+		FullCallerPath:     &fullCallerPath,
+		CompressLogFiles:   &compressLogFiles,
+		AsyncLogFile:       &asyncLogFile,
+		LogFileQueueSize:   1024,
+		LogFileDropPolicy:  DropPolicyBlock,
+		ExitCode:           1,
+		Color:              ColorAuto,
+		ColorDepth:         "auto",
 		TimeFormat:         "2006/Jan/02 15:04:05",
 		LogFileFormat:      "02_Jan_2006_15_04_05.jsonl",
 		LogTimeColor:       palette.GrayDim, // soft “dim white/gray”
+		LogCallerColor:     palette.GrayDim,
 	}
 }
 
+// InitializeConfig applies userConfig to the default logger (and so to Cfg).
 func InitializeConfig(userConfig *Config) {
+	std.InitializeConfig(userConfig)
+}
+
+func (l *Logger) InitializeConfig(userConfig *Config) {
 	// If not provided - just use defaultConfig
 	if userConfig == nil {
-		Log(Notice, palette.PurpleBold, "%s config is %s, keeping %s", "logger", "not provided", "default logger config")
+		l.Log(Notice, palette.PurpleBold, "%s config is %s, keeping %s", "logger", "not provided", "default logger config")
 		return
 	}
-	Log(Notice, palette.GreenBold, "%s config was %s, using %s", "logger", "provided", "user config")
+	l.Log(Notice, palette.GreenBold, "%s config was %s, using %s", "logger", "provided", "user config")
 
 	// apply defaults for every missing value
-	ApplyDefaults(userConfig, Cfg, func(field string, defVal any) {
-		Log(
+	_, err := ApplyDefaults(userConfig, userConfig.explicitDefaults(*l.Cfg), func(field string, defVal any) {
+		l.Log(
 			Info, palette.Purple,
 			"%s field is %s in %s configuration. Using default value: %v",
 			field, "missing", "logger", PrettyForStderr(defVal),
 		)
 	})
+	if err != nil {
+		l.Log(Error, palette.Red, "Err: '%s', errMsg: '%s'", err, "Some logger config defaults are invalid, leaving those fields empty")
+	}
 
 	// use user config after applying defaults
-	Cfg = *userConfig
-	Log(Info, palette.GreenDim, "%s: %s", "Effective config", Cfg)
+	*l.Cfg = *userConfig
+	l.levels.reset(l.Cfg)
+	l.out.mu.Lock()
+	l.out.depth = depthCache{} // Color or ColorDepth may have changed, and so may the environment
+	l.out.mu.Unlock()
+	l.sampler.reset(l, l.Cfg.Sampling)
+	l.Log(Info, palette.GreenDim, "%s: %s", "Effective config", *l.Cfg)
 
-	if Cfg.LogDir != "" {
-		// this function will change Cfg.LoggerFilePath and Cfg.LoggerFile
-		err, errMsg := OpenLoggerFile(Cfg.LogDir)
+	if l.Cfg.LogDir != "" {
+		// this function will change the file sink of this logger
+		err, errMsg := l.OpenLoggerFile(l.Cfg.LogDir)
 		if err != nil {
-			Log(Info, palette.Red, "Err: '%s', errMsg: '%s'", err, errMsg)
-			os.Exit(1)
+			l.Fatal(Critical, palette.Red, "Err: '%s', errMsg: '%s'", err, errMsg)
 		}
 	}
 }
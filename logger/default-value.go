@@ -1,22 +1,55 @@
 package tl
 
 import (
+	"encoding"
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 /*
-Fills zero-valued fields in dst from def.
+Fills zero-valued fields in dst from def and returns the dotted path of every
+filled field, like "db.pool.max_idle" (JSON names where there are tags).
 
-It logs each fill via logf(fieldName, defaultValue) if provided.
+It logs each fill via logf(fieldPath, defaultValue) if provided.
 
-Respects struct tag `default:"skip"` to skip a field.
+Nested structs are filled field by field, unless they are entirely zero and
+the default isn't: then the whole default section is copied (and every field
+set in it is reported). A nil pointer to
+a struct gets a copy of the default one, a set pointer is filled like a nested
+struct. Maps get the default keys they don't have yet. Structs with func
+fields (like palette.Colorizer) and fields tagged json:"-" are not descended
+into, they are filled as a whole when zero.
+
+A field whose default in def is zero can have a literal default in its tag,
+for strings, bools, numbers, durations and types implementing
+encoding.TextUnmarshaler (like LogLevel):
+
+	Timeout time.Duration `json:"timeout" default:"30s"`
+	Level   LogLevel      `json:"level" default:"info+5"`
 
-For now this function only handles top-level fields.
+A literal that doesn't parse leaves its field zero and is returned in err,
+the other fields are still filled.
+Respects struct tag `default:"skip"` to skip a field.
 */
-func ApplyDefaults[T any](dst *T, def T, logf func(field string, defVal any)) {
-	v := reflect.ValueOf(dst).Elem()
-	dv := reflect.ValueOf(def)
+func ApplyDefaults[T any](dst *T, def T, logf func(field string, defVal any)) (filled []string, err error) {
+	d := defaultFiller{logf: logf}
+	d.applyStruct(reflect.ValueOf(dst).Elem(), reflect.ValueOf(def), "")
+	return d.filled, errors.Join(d.errs...)
+}
+
+// defaultFiller walks ApplyDefaults structs, collecting the filled paths and bad tag literals
+type defaultFiller struct {
+	logf   func(field string, defVal any)
+	filled []string
+	errs   []error
+}
+
+// applyStruct fills the zero fields of struct v from struct dv; prefix is the path of v
+func (d *defaultFiller) applyStruct(v, dv reflect.Value, prefix string) {
 	t := v.Type()
 
 	for i := 0; i < t.NumField(); i++ {
@@ -29,21 +62,206 @@ func ApplyDefaults[T any](dst *T, def T, logf func(field string, defVal any)) {
 		}
 
 		// Optional opt-out
-		if sf.Tag.Get("default") == "skip" {
+		tag := sf.Tag.Get("default")
+		if tag == "skip" {
 			continue
 		}
 
-		// Zero or replaceable?
-		if IsZeroOrEmpty(f) {
-			// Fill from default
-			f.Set(dv.Field(i))
+		d.applyField(f, dv.Field(i), fieldPath(prefix, sf), tag, isWholeField(sf))
+	}
+}
+
+// fieldPath is the dotted path of sf in a struct at prefix.
+// Embedded structs without a json name are flattened, like encoding/json does.
+func fieldPath(prefix string, sf reflect.StructField) string {
+	if sf.Anonymous && sf.Tag.Get("json") == "" {
+		return prefix
+	}
+	return joinFieldPath(prefix, jsonOrFieldName(sf))
+}
+
+// runtime-only fields (json:"-") are filled as single values
+func isWholeField(sf reflect.StructField) bool {
+	return sf.Tag.Get("json") == "-"
+}
+
+func (d *defaultFiller) applyField(f, df reflect.Value, path, tag string, whole bool) {
+	switch {
+	case !whole && isConfigSection(f.Type()):
+		if IsZeroOrEmpty(f) && !IsZeroOrEmpty(df) {
+			f.Set(df)
+			d.reportSection(f, path)
+			// the copied section can still have tag defaults
+			df = reflect.Zero(f.Type())
+		}
+		d.applyStruct(f, df, path)
 
-			if logf != nil {
-				name := jsonOrFieldName(sf)
-				logf(name, dv.Field(i).Interface())
+	case !whole && f.Kind() == reflect.Pointer && isConfigSection(f.Type().Elem()):
+		if f.IsNil() {
+			// a nil section stays nil (often meaning "disabled") unless def has one
+			if df.IsNil() {
+				return
 			}
+			p := reflect.New(f.Type().Elem())
+			p.Elem().Set(df.Elem())
+			f.Set(p)
+			d.reportSection(f.Elem(), path)
+			// the copied section can still have tag defaults
+			df = reflect.Zero(f.Type())
+		}
+		if df.IsNil() {
+			df = reflect.New(f.Type().Elem())
+		}
+		d.applyStruct(f.Elem(), df.Elem(), path)
+
+	case f.Kind() == reflect.Map && f.Len() > 0:
+		// merge: add default keys that are missing
+		iter := df.MapRange()
+		for iter.Next() {
+			if !f.MapIndex(iter.Key()).IsValid() {
+				f.SetMapIndex(iter.Key(), iter.Value())
+				d.report(joinFieldPath(path, fmt.Sprint(iter.Key().Interface())), iter.Value())
+			}
+		}
+
+	case IsZeroOrEmpty(f):
+		// Fill from default, the tag is only used when def has nothing
+		if tag == "" && df.IsZero() {
+			return // nothing to fill with
+		}
+		if tag != "" && IsZeroOrEmpty(df) {
+			value, err := parseDefaultTag(f.Type(), tag)
+			if err != nil {
+				d.errs = append(d.errs, fmt.Errorf("%s: bad default %q: %w", path, tag, err))
+				return
+			}
+			df = value
+		}
+		d.fill(f, df, path)
+	}
+}
+
+// fill sets f to value; maps are copied so that merging into dst later doesn't change def
+func (d *defaultFiller) fill(f, value reflect.Value, path string) {
+	if value.Kind() == reflect.Map && !value.IsNil() {
+		m := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+		value = m
+	}
+	f.Set(value)
+	d.report(path, value)
+}
+
+// reportSection reports every set field of a section copied from the defaults
+func (d *defaultFiller) reportSection(v reflect.Value, path string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
 		}
+		f, fPath := v.Field(i), fieldPath(path, sf)
+		switch {
+		case isWholeField(sf):
+		case isConfigSection(f.Type()):
+			d.reportSection(f, fPath)
+			continue
+		case f.Kind() == reflect.Pointer && !f.IsNil() && isConfigSection(f.Type().Elem()):
+			d.reportSection(f.Elem(), fPath)
+			continue
+		}
+		if !IsZeroOrEmpty(f) {
+			d.report(fPath, f)
+		}
+	}
+}
+
+func (d *defaultFiller) report(path string, value reflect.Value) {
+	d.filled = append(d.filled, path)
+	if d.logf != nil {
+		d.logf(path, value.Interface())
+	}
+}
+
+func joinFieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// isConfigSection reports whether t is a struct ApplyDefaults descends into.
+// Structs like time.Time (only unexported fields) or palette.Colorizer (a func
+// field, its parts only make sense together) are filled as single values.
+func isConfigSection(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	exported := false
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Type.Kind() == reflect.Func {
+			return false
+		}
+		exported = exported || t.Field(i).IsExported()
+	}
+	return exported
+}
+
+// parseDefaultTag parses a `default:"..."` literal into a value of type t
+func parseDefaultTag(t reflect.Type, tag string) (reflect.Value, error) {
+	if t.Kind() == reflect.Pointer {
+		elem, err := parseDefaultTag(t.Elem(), tag)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(elem)
+		return p, nil
+	}
+
+	v := reflect.New(t).Elem()
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return v, u.UnmarshalText([]byte(tag))
+	}
+	if t == reflect.TypeOf(time.Duration(0)) {
+		duration, err := time.ParseDuration(tag)
+		v.SetInt(int64(duration))
+		return v, err
+	}
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(tag)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(tag)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(tag, 0, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(tag, 0, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		x, err := strconv.ParseFloat(tag, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(x)
+	default:
+		return v, fmt.Errorf("%s can't have a literal default", t)
 	}
+	return v, nil
 }
 
 // Treats zero values as zero, and ALSO treats slices with len==0 as zero
@@ -0,0 +1,59 @@
+package tl
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// a colorizer is one value: a set one must not get the Fn of the default
+func TestApplyDefaultsKeepsColorizer(t *testing.T) {
+	cfg := Config{LogTimeColor: palette.NoColor}
+	filled, err := ApplyDefaults(&cfg, defaultConfig(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameFn := reflect.ValueOf(cfg.LogTimeColor.Fn).Pointer() == reflect.ValueOf(palette.NoColor.Fn).Pointer()
+	if !sameFn || cfg.LogTimeColor.Name != palette.NoColor.Name {
+		t.Errorf("LogTimeColor = %s, want NoColor", cfg.LogTimeColor.Name)
+	}
+	if slices.Contains(filled, "LogTimeColor.Fn") || slices.Contains(filled, "LogTimeColor") {
+		t.Errorf("filled %v", filled)
+	}
+	if !slices.Contains(filled, "LogCallerColor") {
+		t.Errorf("LogCallerColor not filled: %v", filled)
+	}
+}
+
+// a copied section reports its fields, and a bad tag literal is an error, not a panic
+func TestApplyDefaultsSections(t *testing.T) {
+	type pool struct {
+		MaxIdle int    `json:"max_idle"`
+		Mode    string `json:"mode" default:"lazy"`
+		Unused  string `json:"unused"`
+	}
+	type db struct {
+		Pool    pool          `json:"pool"`
+		Timeout time.Duration `json:"timeout" default:"soon"`
+	}
+	type config struct {
+		DB db `json:"db"`
+	}
+
+	var cfg config
+	filled, err := ApplyDefaults(&cfg, config{DB: db{Pool: pool{MaxIdle: 4}}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "db.timeout") {
+		t.Errorf("err = %v, want a bad default of db.timeout", err)
+	}
+	if cfg.DB.Pool.MaxIdle != 4 || cfg.DB.Pool.Mode != "lazy" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	want := []string{"db.pool.max_idle", "db.pool.mode"}
+	if !slices.Equal(filled, want) {
+		t.Errorf("filled %v, want %v", filled, want)
+	}
+}
@@ -0,0 +1,86 @@
+package tl
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// ExitFunc is called by Fatal to end the program. Replace it in tests to
+// catch the exit code instead of exiting; Fatal returns if ExitFunc returns.
+var ExitFunc = os.Exit
+
+var (
+	exitHooks   []func()
+	exitHooksMu sync.Mutex
+	// set while exit hooks run, so a hook calling Fatal doesn't run them again
+	exiting atomic.Bool
+)
+
+// RegisterExitHook adds a function that Fatal runs before exiting, in registration order.
+func RegisterExitHook(hook func()) {
+	exitHooksMu.Lock()
+	exitHooks = append(exitHooks, hook)
+	exitHooksMu.Unlock()
+}
+
+func runExitHooks() {
+	if !exiting.CompareAndSwap(false, true) {
+		return
+	}
+	defer exiting.Store(false)
+
+	exitHooksMu.Lock()
+	hooks := append([]func(){}, exitHooks...)
+	exitHooksMu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+/*
+Fatal logs a line at a Critical level and ends the program:
+  - all goroutine stacks are saved in the "stack" field of the JSONL line
+  - the log file is flushed
+  - exit hooks run (see RegisterExitHook)
+  - the log file is closed and ExitFunc is called with Cfg.ExitCode
+
+Levels outside the Critical band (0-9) are logged as Critical.
+*/
+func (l *Logger) Fatal(level LogLevel, colorize palette.Colorizer, format string, args ...any) {
+	if level < Critical || level > Critical9 {
+		level = Critical
+	}
+	l.logBool(level, colorize, true, entry{stack: allStacks()}, format, args...)
+
+	_ = l.Flush()
+	runExitHooks()
+	// hooks may log too
+	_ = l.Close()
+
+	exitCode := l.Cfg.ExitCode
+	if exitCode == 0 {
+		exitCode = 1
+	}
+	ExitFunc(exitCode)
+}
+
+// Fatal logs through the default logger and exits, see (*Logger).Fatal.
+func Fatal(level LogLevel, colorize palette.Colorizer, format string, args ...any) {
+	std.Fatal(level, colorize, format, args...)
+}
+
+// allStacks returns stack traces of all goroutines, growing the buffer until they fit
+func allStacks() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
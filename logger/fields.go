@@ -0,0 +1,158 @@
+package tl
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// Field is a key/value pair attached to a log line.
+// Fields are saved as a "fields" object in JSONL and printed as key=value pairs.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// F is a shorthand for Field{Key: key, Value: value}.
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+/*
+With returns a logger that attaches the given key/value pairs to every line.
+keyvals alternate between keys and values, for example:
+
+	reqLog := logger.With("request_id", id, "user_id", userID)
+
+A Field can be passed in place of a key/value pair. A key without a value
+gets "!MISSING" as its value. The returned logger shares config, output
+and log file with l.
+*/
+func (l *Logger) With(keyvals ...any) *Logger {
+	return l.WithFields(fieldsFromKeyvals(keyvals)...)
+}
+
+// WithFields returns a logger that attaches fields to every line, see With.
+func (l *Logger) WithFields(fields ...Field) *Logger {
+	derived := *l
+	derived.fields = mergeFields(l.fields, fields)
+	return &derived
+}
+
+// Fields returns the fields attached to this logger.
+func (l *Logger) Fields() []Field {
+	return append([]Field(nil), l.fields...)
+}
+
+// LogFields logs a line with extra fields attached to this call only.
+func (l *Logger) LogFields(level LogLevel, colorize palette.Colorizer, fields []Field, format string, args ...any) {
+	l.logBool(level, colorize, true, entry{fields: fields}, format, args...)
+}
+
+// With returns a derived default logger, see (*Logger).With.
+func With(keyvals ...any) *Logger {
+	return std.With(keyvals...)
+}
+
+// LogFields logs through the default logger, see (*Logger).LogFields.
+func LogFields(level LogLevel, colorize palette.Colorizer, fields []Field, format string, args ...any) {
+	std.logBool(level, colorize, true, entry{fields: fields}, format, args...)
+}
+
+func fieldsFromKeyvals(keyvals []any) []Field {
+	var fields []Field
+	for i := 0; i < len(keyvals); i++ {
+		if f, ok := keyvals[i].(Field); ok {
+			fields = append(fields, f)
+			continue
+		}
+		key := fmt.Sprint(keyvals[i])
+		if i+1 >= len(keyvals) {
+			fields = append(fields, F(key, "!MISSING"))
+			break
+		}
+		fields = append(fields, F(key, keyvals[i+1]))
+		i++
+	}
+	return fields
+}
+
+// mergeFields returns base followed by extra; an extra field replaces a base field with the same key.
+func mergeFields(base, extra []Field) []Field {
+	if len(extra) == 0 {
+		return base
+	}
+	out := append([]Field(nil), base...)
+	for _, f := range extra {
+		replaced := false
+		for i := range out {
+			if out[i].Key == f.Key {
+				out[i] = f
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// sanitizeFields converts fields to the JSONL "fields" object.
+func sanitizeFields(fields []Field) map[string]any {
+	if len(fields) == 0 {
+		return nil
+	}
+	out := make(map[string]any, len(fields))
+	for _, f := range fields {
+		out[f.Key] = sanitizeArg(f.Value)
+	}
+	return out
+}
+
+// SortedFields turns a JSONL "fields" object back into fields, sorted by key.
+func SortedFields(m map[string]any) []Field {
+	fields := make([]Field, 0, len(m))
+	for k, v := range m {
+		fields = append(fields, F(k, v))
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	return fields
+}
+
+/*
+RenderFields renders fields as space-separated key=value pairs.
+Values are colored with colorize, keys are left as is.
+Values with spaces, quotes or '=' are quoted.
+*/
+func RenderFields(fields []Field, colorize palette.Colorizer) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		parts = append(parts, f.Key+"="+colorize.Apply(FieldValueString(f.Value)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// FieldValueString renders a field value on a single line.
+func FieldValueString(v any) string {
+	var s string
+	switch v.(type) {
+	case string, error, fmt.Stringer, []byte:
+		s = PrettyForStderr(v)
+	default:
+		if b, err := json.Marshal(v); err == nil {
+			s = string(b)
+		} else {
+			s = fmt.Sprintf("%+v", v)
+		}
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -1,22 +1,58 @@
 package tl
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tuumbleweed/tintlog/palette"
 )
 
 var (
-	LoggerFile      *os.File
-	LoggerFilePath  string
+	// Deprecated: use LogFilePath. The file of the default logger, kept up to date
+	// with rotation; lock LoggerFileMutex to use it.
+	LoggerFile *os.File
+	// Deprecated: use LogFilePath. The path of LoggerFile, "" if file logging is off.
+	LoggerFilePath string
+	// Deprecated: use Flush and Close. Guards LoggerFile and LoggerFilePath.
 	LoggerFileMutex sync.Mutex
 )
 
+// fileSink is the JSONL file a logger (and every logger derived from it) writes to.
+type fileSink struct {
+	// a pointer, so the default logger can use LoggerFileMutex
+	mu   *sync.Mutex
+	file *os.File
+	path string
+	// copy file and path to LoggerFile and LoggerFilePath, for the default logger
+	publish bool
+
+	// set when the file is opened, see rotate.go
+	dir        string
+	format     string
+	maxSize    int64
+	every      time.Duration
+	size       int64
+	nextRotate time.Time
+
+	// rotated files cleanup, see retention.go
+	retention retention
+	// files this sink rotated away, the only recently written ones cleanup touches
+	rotated   map[string]bool
+	cleanupMu sync.Mutex
+	cleanupWG sync.WaitGroup
+
+	// async mode, see async.go
+	async atomic.Pointer[asyncQueue]
+	bw    *bufio.Writer
+	// lets writeLogJSONL skip marshaling without taking mu
+	opened atomic.Bool
+}
+
 type LogLine struct {
 	Time   time.Time `json:"time"`
 	TID    int       `json:"tid,omitempty"`
@@ -24,6 +60,26 @@ type LogLine struct {
 	Color  string    `json:"color,omitempty"` // e.g., "Green"
 	Format string    `json:"format"`          // original format string
 	Args   []any     `json:"args"`            // sanitized args (no ANSI)
+	// key/value fields attached with With or LogFields (sanitized, no ANSI)
+	Fields map[string]any `json:"fields,omitempty"`
+	// all goroutine stacks, only for Fatal lines
+	Stack string `json:"stack,omitempty"`
+	// call site, only if Cfg.UseCaller is on
+	Caller *Caller `json:"caller,omitempty"`
+	// name of the component logger, see Component
+	Component string `json:"component,omitempty"`
+}
+
+// MarshalJSON keeps "level" a number in JSONL files, while LogLevel alone marshals to its name.
+func (l LogLine) MarshalJSON() ([]byte, error) {
+	type plainLine LogLine // without this method
+	// time and tid are repeated so the keys keep their order
+	return json.Marshal(struct {
+		Time  time.Time `json:"time"`
+		TID   int       `json:"tid,omitempty"`
+		Level int       `json:"level"`
+		plainLine
+	}{l.Time, l.TID, int(l.Level), plainLine(l)})
 }
 
 /*
@@ -32,27 +88,66 @@ This will initiate json logging file with name like Cfg.LogFileFormat and conten
 
 This function is only called if an option to save to logger file is specified when initializing logr.
 
-This function will change Cfg.LoggerFilePath and Cfg.LoggerFile
+This function opens the file of the default logger, see (*Logger).OpenLoggerFile.
 */
 func OpenLoggerFile(logDir string) (err error, errMsg string) {
+	return std.OpenLoggerFile(logDir)
+}
+
+// OpenLoggerFile opens a JSONL file in logDir named from l.Cfg.LogFileFormat
+// and makes it the file sink of this logger.
+func (l *Logger) OpenLoggerFile(logDir string) (err error, errMsg string) {
 	err, errMsg = CreateDirIfDoesntExist(logDir)
 	if err != nil {
 		return err, errMsg
 	}
 
-	LoggerFilePath = filepath.Join(logDir, time.Now().Format(Cfg.LogFileFormat))
+	every, err := parseRotateEvery(l.Cfg.LogFileRotateEvery)
+	if err != nil {
+		return err, fmt.Sprintf("Invalid log_file_rotate_every: '%s'", l.Cfg.LogFileRotateEvery)
+	}
+	maxAge, err := parseMaxAge(l.Cfg.LogFileMaxAge)
+	if err != nil {
+		return err, fmt.Sprintf("Invalid log_file_max_age: '%s'", l.Cfg.LogFileMaxAge)
+	}
 
-	Log(Notice, palette.Blue, "%s log file '%s'", "Creating", LoggerFilePath)
-	LoggerFile, err = os.OpenFile(LoggerFilePath, os.O_WRONLY|os.O_CREATE, 0644)
+	l.Log(Notice, palette.Blue, "%s log file in '%s'", "Creating", logDir)
+	l.sink.mu.Lock()
+	l.sink.dir = logDir
+	l.sink.format = l.Cfg.LogFileFormat
+	l.sink.maxSize = l.Cfg.LogFileMaxSize
+	l.sink.every = every
+	l.sink.retention = retention{
+		maxCount: l.Cfg.LogFileMaxCount,
+		maxAge:   maxAge,
+		compress: l.Cfg.CompressLogFiles != nil && *l.Cfg.CompressLogFiles,
+	}
+	old, oldPath := l.sink.file, l.sink.path
+	err = l.sink.openLocked(time.Now())
+	path := l.sink.path
+	if err == nil {
+		if old != nil {
+			l.sink.markRotatedLocked(oldPath)
+		}
+		// files left by previous runs count as rotated
+		l.sink.startCleanupLocked()
+		if l.Cfg.AsyncLogFile != nil && *l.Cfg.AsyncLogFile {
+			l.sink.startAsyncLocked(l.Cfg.LogFileQueueSize, l.Cfg.LogFileDropPolicy)
+		}
+	}
+	l.sink.mu.Unlock()
 	if err != nil {
-		return err, fmt.Sprintf("Unable to open file: '%s'", LoggerFilePath)
+		return err, fmt.Sprintf("Unable to open file: '%s'", path)
+	}
+	if old != nil {
+		_ = old.Close()
 	}
 
-	Log(Notice1, palette.Green, "%s log file '%v'", "Created", LoggerFilePath)
+	l.Log(Notice1, palette.Green, "%s log file '%v'", "Created", path)
 	return nil, ""
 }
 
-// Cfg.LoggerFilePath == "" at the point of logger initialization, so
+// The log file path is "" at the point of logger initialization, so
 // it will just print without saving to log file
 func CreateDirIfDoesntExist(path string) (err error, errMsg string) {
 	Log(Info, palette.Blue, "%s dir: '%s'", "Creating", path)
@@ -77,15 +172,19 @@ func CreateDirIfDoesntExist(path string) (err error, errMsg string) {
 	return nil, ""
 }
 
-func writeLogJSONL(line LogLine) {
-	if LoggerFilePath == "" {
+func (l *Logger) writeLogJSONL(line LogLine) {
+	if !l.sink.opened.Load() {
 		return
 	}
 	b, err := json.Marshal(line)
 	if err != nil {
 		return
 	}
-	LoggerFileMutex.Lock()
-	_, _ = LoggerFile.Write(append(b, '\n'))
-	LoggerFileMutex.Unlock()
+	if q := l.sink.async.Load(); q != nil {
+		q.push(queuedLine{b: append(b, '\n'), level: line.Level})
+		return
+	}
+	l.sink.mu.Lock()
+	l.sink.writeLocked(append(b, '\n'))
+	l.sink.mu.Unlock()
 }
@@ -0,0 +1,201 @@
+package tl
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+/*
+levelState holds the levels a logger prints by, shared by every logger derived from it.
+
+It starts from Cfg.LogLevel and Cfg.ComponentLevels (see InitializeConfig) and
+can be changed at runtime with SetLevel and SetComponentLevel, by signals
+(see HandleLevelSignals) or over HTTP (see LevelHandler).
+Every change is copied back into the logger's Cfg, so Cfg.LogLevel always
+shows the current level; writing Cfg.LogLevel directly changes nothing.
+*/
+type levelState struct {
+	level atomic.Int64
+	// copied on write, never modified in place
+	components atomic.Pointer[map[string]LogLevel]
+	// serializes updates, of components and of cfg
+	mu sync.Mutex
+	// the config of the logger, kept in sync with the levels
+	cfg *Config
+}
+
+func newLevelState(cfg *Config) *levelState {
+	s := &levelState{cfg: cfg}
+	s.reset(cfg)
+	return s
+}
+
+// reset takes the levels from cfg
+func (s *levelState) reset(cfg *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.level.Store(int64(cfg.LogLevel))
+	components := maps.Clone(cfg.ComponentLevels)
+	s.components.Store(&components)
+	s.cfg.LogLevel, s.cfg.ComponentLevels = cfg.LogLevel, maps.Clone(components)
+}
+
+// Level returns the level this logger prints by (not counting component levels).
+func (l *Logger) Level() LogLevel {
+	return LogLevel(l.levels.level.Load())
+}
+
+// SetLevel changes the level this logger, and every logger sharing its config, prints by.
+// Safe to call while other goroutines log. Cfg.LogLevel is updated too.
+func (l *Logger) SetLevel(level LogLevel) {
+	s := l.levels
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.level.Store(int64(level))
+	s.cfg.LogLevel = level
+}
+
+// ComponentLevels returns a copy of the current component levels, see Config.ComponentLevels.
+func (l *Logger) ComponentLevels() map[string]LogLevel {
+	return maps.Clone(*l.levels.components.Load())
+}
+
+// SetComponentLevel sets the level of components matching pattern, see Config.ComponentLevels.
+func (l *Logger) SetComponentLevel(pattern string, level LogLevel) {
+	l.updateComponentLevels(func(m map[string]LogLevel) { m[pattern] = level })
+}
+
+// RemoveComponentLevel removes the level set for pattern, so those components fall back to other patterns.
+func (l *Logger) RemoveComponentLevel(pattern string) {
+	l.updateComponentLevels(func(m map[string]LogLevel) { delete(m, pattern) })
+}
+
+func (l *Logger) updateComponentLevels(update func(map[string]LogLevel)) {
+	s := l.levels
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	components := maps.Clone(*s.components.Load())
+	if components == nil {
+		components = make(map[string]LogLevel)
+	}
+	update(components)
+	s.components.Store(&components)
+	s.cfg.ComponentLevels = maps.Clone(components)
+}
+
+// SetLevel changes the level of the default logger, see (*Logger).SetLevel.
+func SetLevel(level LogLevel) {
+	std.SetLevel(level)
+}
+
+// SetComponentLevel sets a component level of the default logger, see (*Logger).SetComponentLevel.
+func SetComponentLevel(pattern string, level LogLevel) {
+	std.SetComponentLevel(pattern, level)
+}
+
+// levelsBody is the JSON of LevelHandler responses and PUT requests
+type levelsBody struct {
+	Level      LogLevel            `json:"level"`
+	Components map[string]LogLevel `json:"components,omitempty"`
+}
+
+/*
+LevelHandler returns an http.Handler to read and change levels at runtime.
+
+GET responds with the current levels:
+
+	{"level":"Info5","components":{"db":"Notice"}}
+
+PUT changes them. The body is either a level alone (any form ParseLogLevel
+accepts, like "Info5", "info+5" or 55), or a JSON object with "level" and/or "components"; a component
+set to null is removed:
+
+	curl -X PUT -d Verbose localhost:6060/log/level
+	curl -X PUT -d '{"components":{"db":"Debug","http.*":null}}' localhost:6060/log/level
+
+The handler has no authentication, serve it on a local or otherwise protected address.
+*/
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			if err := l.putLevels(r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(levelsBody{
+			Level:      l.Level(),
+			Components: l.ComponentLevels(),
+		})
+	})
+}
+
+// LevelHandler returns an http.Handler for the default logger, see (*Logger).LevelHandler.
+func LevelHandler() http.Handler {
+	return std.LevelHandler()
+}
+
+// putLevels applies a LevelHandler PUT body; nothing changes if any part of it is invalid
+func (l *Logger) putLevels(body io.Reader) error {
+	data, err := io.ReadAll(io.LimitReader(body, 64*1024))
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(text, "{") {
+		var level LogLevel
+		if err = json.Unmarshal(data, &level); err != nil {
+			// a bare name isn't JSON
+			if level, err = ParseLogLevel(text); err != nil {
+				return err
+			}
+		}
+		l.SetLevel(level)
+		l.Log(Notice, palette.Blue, "Log level set to %s over HTTP", level.String())
+		return nil
+	}
+
+	// a component set to null decodes to a nil level
+	var req struct {
+		Level      *LogLevel            `json:"level"`
+		Components map[string]*LogLevel `json:"components"`
+	}
+	if err = json.Unmarshal(data, &req); err != nil {
+		return err
+	}
+	level, components := req.Level, req.Components
+
+	if level != nil {
+		l.SetLevel(*level)
+		l.Log(Notice, palette.Blue, "Log level set to %s over HTTP", level.String())
+	}
+	for pattern, level := range components {
+		if level == nil {
+			l.RemoveComponentLevel(pattern)
+			l.Log(Notice, palette.Blue, "Level of component %s removed over HTTP", pattern)
+			continue
+		}
+		l.SetComponentLevel(pattern, *level)
+		l.Log(Notice, palette.Blue, "Level of component %s set to %s over HTTP", pattern, level.String())
+	}
+	return nil
+}
+
+// HandleLevelSignals handles level signals for the default logger, see (*Logger).HandleLevelSignals.
+func HandleLevelSignals() (stop func()) {
+	return std.HandleLevelSignals()
+}
@@ -0,0 +1,34 @@
+package tl
+
+import (
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// Cfg shows the levels set at runtime, while other goroutines log
+func TestSetLevelUpdatesConfig(t *testing.T) {
+	l := NewLogger(nil)
+	l.SetOutput(io.Discard)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			l.Component("db").Log(Info, palette.Green, "line")
+		}
+	}()
+	l.SetLevel(Verbose)
+	l.SetComponentLevel("db", Notice)
+	wg.Wait()
+
+	if l.Level() != Verbose || l.Cfg.LogLevel != Verbose {
+		t.Errorf("Level() = %s, Cfg.LogLevel = %s, want Verbose", l.Level(), l.Cfg.LogLevel)
+	}
+	if l.Cfg.ComponentLevels["db"] != Notice {
+		t.Errorf("Cfg.ComponentLevels = %v", l.Cfg.ComponentLevels)
+	}
+}
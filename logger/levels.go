@@ -1,6 +1,12 @@
 package tl
 
-import "fmt"
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // LogLevel is a custom type for log levels
 type LogLevel int
@@ -141,3 +147,104 @@ func (logLevel LogLevel) String() string {
 
 	return logLevels[logLevel]
 }
+
+// the highest level, Config.LogLevel uses it by default to print everything
+const maxLogLevel LogLevel = 99
+
+/*
+ParseLogLevel parses a level in any of these forms (names are case-insensitive):
+
+	Warning      a band name
+	Warning5     a level name
+	warning+3    a name plus (or minus) an offset, Warning3 here
+	25           a number from 0 to 99
+*/
+func ParseLogLevel(s string) (LogLevel, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return checkLevelRange(n, s)
+	}
+
+	name, offset := s, 0
+	if i := strings.LastIndexAny(s, "+-"); i > 0 {
+		n, err := strconv.Atoi(strings.TrimSpace(s[i+1:]))
+		if err != nil {
+			return 0, fmt.Errorf("bad offset in log level %q", s)
+		}
+		name, offset = strings.TrimSpace(s[:i]), n
+		if s[i] == '-' {
+			offset = -n
+		}
+	}
+	for level := Critical; level <= Debug9; level++ {
+		if strings.EqualFold(level.String(), name) {
+			return checkLevelRange(int(level)+offset, s)
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func checkLevelRange(n int, s string) (LogLevel, error) {
+	if n < int(Critical) || n > int(maxLogLevel) {
+		return 0, fmt.Errorf("log level %q is out of range %d-%d", s, Critical, maxLogLevel)
+	}
+	return LogLevel(n), nil
+}
+
+// MarshalText returns the level name, or the number for levels above Debug9.
+func (logLevel LogLevel) MarshalText() ([]byte, error) {
+	if logLevel < Critical || logLevel > maxLogLevel {
+		return nil, fmt.Errorf("log level %d is out of range %d-%d", int(logLevel), Critical, maxLogLevel)
+	}
+	if logLevel > Debug9 {
+		return []byte(strconv.Itoa(int(logLevel))), nil
+	}
+	return []byte(logLevel.String()), nil
+}
+
+// UnmarshalText parses any form ParseLogLevel accepts.
+func (logLevel *LogLevel) UnmarshalText(text []byte) error {
+	level, err := ParseLogLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*logLevel = level
+	return nil
+}
+
+// UnmarshalJSON accepts a level name (see ParseLogLevel) or a bare number, as older files have it.
+func (logLevel *LogLevel) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("log level must be a name or a number, got %s", data)
+		}
+		*logLevel, err = checkLevelRange(n, string(data))
+		return err
+	}
+	return logLevel.UnmarshalText([]byte(s))
+}
+
+// Set makes *LogLevel a flag.Value, see LevelFlag.
+func (logLevel *LogLevel) Set(s string) error {
+	return logLevel.UnmarshalText([]byte(s))
+}
+
+// LevelFlag defines a level flag that accepts any form ParseLogLevel does, like flag.Int.
+func LevelFlag(name string, value LogLevel, usage string) *LogLevel {
+	p := new(LogLevel)
+	*p = value
+	flag.Var(p, name, usage)
+	return p
+}
+
+/*
+StepBand moves level by n bands (down for negative n) and returns the end of
+the band it lands in, like Warning9 or Info9, so the whole band is printed.
+The result stays between Critical9 and 99.
+*/
+func StepBand(level LogLevel, n int) LogLevel {
+	band := int(level)/10 + n
+	return LogLevel(min(max(band*10+9, int(Critical9)), int(maxLogLevel)))
+}
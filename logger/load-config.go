@@ -0,0 +1,416 @@
+package tl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// prefix of environment variables read by ApplyEnv
+const envPrefix = "TINTLOG_"
+
+/*
+LoadConfig reads a logger config from a JSON file, applies environment
+overrides (see ApplyEnv) and validates the result (see Validate).
+
+The file is either a config of the whole program with a "logger" section,
+
+	{"logger": {"log_level": "Info5", "log_dir": "log"}, "db": {...}}
+
+or just the logger section itself. A file with other sections (objects under
+keys that aren't logger settings) but no "logger" one leaves the logger config
+empty. Levels can be numbers or names, see ParseLogLevel.
+Unknown keys in the logger section are errors, they are usually typos.
+With an empty path only the environment is read.
+
+Missing values stay zero, InitializeConfig fills them from the defaults:
+
+	cfg, err, errMsg := tl.LoadConfig("config.json")
+	if err != nil {
+		tl.Fatal(tl.Critical, palette.Red, "Err: '%s', errMsg: '%s'", err, errMsg)
+	}
+	tl.InitializeConfig(cfg)
+*/
+func LoadConfig(path string) (cfg *Config, err error, errMsg string) {
+	cfg = &Config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err, fmt.Sprintf("Unable to read config file: '%s'", path)
+		}
+		if err = decodeConfig(data, cfg); err != nil {
+			return nil, err, fmt.Sprintf("Invalid logger config in '%s'", path)
+		}
+	}
+	if err = ApplyEnv(cfg); err != nil {
+		return nil, err, "Invalid TINTLOG_ environment variable"
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, err, "Invalid logger config"
+	}
+	return cfg, nil, ""
+}
+
+// decodeConfig decodes the "logger" section of data into cfg. Without one,
+// data is the logger section itself, unless it has sections of other parts of the program.
+func decodeConfig(data []byte, cfg *Config) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	section := data
+	if raw, ok := doc["logger"]; ok {
+		section = raw
+	} else if hasOtherSections(doc) {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(section))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return err
+	}
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(section, &keys); err != nil {
+		return err
+	}
+	for key, raw := range keys {
+		if string(raw) != "null" {
+			cfg.markSet(key)
+		}
+	}
+	return nil
+}
+
+// hasOtherSections reports whether doc has an object under a key that isn't a Config field
+func hasOtherSections(doc map[string]json.RawMessage) bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		fields[jsonOrFieldName(t.Field(i))] = true
+	}
+	for key, raw := range doc {
+		if !fields[key] && bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+			return true
+		}
+	}
+	return false
+}
+
+// markSet records that key was given explicitly, see Config.set
+func (c *Config) markSet(key string) {
+	if c.set == nil {
+		c.set = make(map[string]bool)
+	}
+	c.set[key] = true
+}
+
+// explicitDefaults returns def with the fields given explicitly in c copied
+// over it, so ApplyDefaults keeps them even when they are zero
+func (c *Config) explicitDefaults(def Config) Config {
+	cv, dv := reflect.ValueOf(c).Elem(), reflect.ValueOf(&def).Elem()
+	for i := 0; i < cv.NumField(); i++ {
+		sf := cv.Type().Field(i)
+		if sf.IsExported() && c.set[jsonOrFieldName(sf)] {
+			dv.Field(i).Set(cv.Field(i))
+		}
+	}
+	return def
+}
+
+/*
+ApplyEnv overrides cfg with TINTLOG_* environment variables, one per JSON key
+of Config in upper case: TINTLOG_LOG_DIR, TINTLOG_USE_TID, TINTLOG_TIME_FORMAT...
+TINTLOG_LEVEL is a short name for TINTLOG_LOG_LEVEL.
+
+Levels are names or numbers, booleans are anything strconv.ParseBool accepts,
+TINTLOG_COMPONENT_LEVELS is a list like "db=Notice,http.*=73" and sections
+are JSON, like TINTLOG_SAMPLING='{"first":100,"thereafter":100}'.
+Empty variables are ignored.
+*/
+func ApplyEnv(cfg *Config) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	var errs []error
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := jsonOrFieldName(sf)
+		if sf.Tag.Get("json") == "-" {
+			continue
+		}
+		name := envPrefix + strings.ToUpper(key)
+		value := strings.TrimSpace(os.Getenv(name))
+		if value == "" && key == "log_level" {
+			name = envPrefix + "LEVEL"
+			value = strings.TrimSpace(os.Getenv(name))
+		}
+		if value == "" {
+			continue
+		}
+		if err := setFromEnv(v.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", name, value, err))
+			continue
+		}
+		cfg.markSet(key)
+	}
+	return errors.Join(errs...)
+}
+
+// setFromEnv parses an environment variable into a Config field
+func setFromEnv(f reflect.Value, value string) error {
+	switch f.Interface().(type) {
+	case LogLevel:
+		level, err := ParseLogLevel(value)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(level))
+		return nil
+	case map[string]LogLevel:
+		levels := make(map[string]LogLevel)
+		for _, part := range strings.Split(value, ",") {
+			pattern, levelStr, ok := strings.Cut(part, "=")
+			if !ok {
+				return fmt.Errorf("expected pattern=level, got %q", part)
+			}
+			level, err := ParseLogLevel(levelStr)
+			if err != nil {
+				return err
+			}
+			levels[strings.TrimSpace(pattern)] = level
+		}
+		f.Set(reflect.ValueOf(levels))
+		return nil
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(&b))
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.Pointer:
+		p := reflect.New(f.Type().Elem())
+		if err := json.Unmarshal([]byte(value), p.Interface()); err != nil {
+			return err
+		}
+		f.Set(p)
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	default:
+		return fmt.Errorf("can't be set from the environment")
+	}
+	return nil
+}
+
+/*
+Validate checks config values and returns every problem found, joined.
+Zero values are not checked, they mean "use the default".
+LogDir must be a writable directory, or a path where one can be created.
+*/
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(key string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+
+	check("log_level", validateLevel(c.LogLevel))
+	for pattern, level := range c.ComponentLevels {
+		check("component_levels: "+pattern, validateLevel(level))
+		if _, err := path.Match(pattern, ""); err != nil {
+			check("component_levels: "+pattern, err)
+		}
+	}
+	if c.TimeFormat != "" {
+		check("time_format", validateTimeLayout(c.TimeFormat))
+	}
+	if c.LogFileFormat != "" {
+		check("log_file_format", validateTimeLayout(c.LogFileFormat))
+		if strings.ContainsAny(c.LogFileFormat, `/\`) {
+			check("log_file_format", errors.New("must be a file name, not a path"))
+		}
+	}
+	if c.LogDir != "" {
+		check("log_dir", validateLogDir(c.LogDir))
+	}
+	switch strings.ToLower(c.Color) {
+	case "", ColorAuto, ColorAlways, ColorNever:
+	default:
+		check("color", fmt.Errorf("%q is not auto, always or never", c.Color))
+	}
+	if _, ok := palette.ParseColorDepth(c.ColorDepth); !ok {
+		check("color_depth", fmt.Errorf("%q is not auto, truecolor, 256, 16 or none", c.ColorDepth))
+	}
+	if _, err := parseRotateEvery(c.LogFileRotateEvery); err != nil {
+		check("log_file_rotate_every", err)
+	}
+	if _, err := parseMaxAge(c.LogFileMaxAge); err != nil {
+		check("log_file_max_age", err)
+	}
+	switch c.LogFileDropPolicy {
+	case "", DropPolicyBlock, DropPolicyDropNewest, DropPolicyDropLowestLevel:
+	default:
+		check("log_file_drop_policy", fmt.Errorf("%q is not %s, %s or %s",
+			c.LogFileDropPolicy, DropPolicyBlock, DropPolicyDropNewest, DropPolicyDropLowestLevel))
+	}
+	if c.LogFileMaxSize < 0 {
+		check("log_file_max_size", errors.New("must not be negative"))
+	}
+	if c.LogFileMaxCount < 0 {
+		check("log_file_max_count", errors.New("must not be negative"))
+	}
+	if c.LogFileQueueSize < 0 {
+		check("log_file_queue_size", errors.New("must not be negative"))
+	}
+	if c.Sampling != nil {
+		check("sampling", c.Sampling.validate())
+	}
+	return errors.Join(errs...)
+}
+
+func validateLevel(level LogLevel) error {
+	if level < Critical || level > maxLogLevel {
+		return fmt.Errorf("%d is out of range %d-%d", level, Critical, maxLogLevel)
+	}
+	return nil
+}
+
+// a layout without any time element formats to itself
+func validateTimeLayout(layout string) error {
+	ref := time.Date(2025, time.November, 9, 18, 19, 26, 0, time.UTC)
+	formatted := ref.Format(layout)
+	if formatted == layout {
+		return fmt.Errorf("%q has no time elements, see the layouts in package time", layout)
+	}
+	if _, err := time.Parse(layout, formatted); err != nil {
+		return fmt.Errorf("%q: %w", layout, err)
+	}
+	return nil
+}
+
+// validateLogDir checks that dir (or the closest existing parent, if dir doesn't exist yet) is a writable directory
+func validateLogDir(dir string) error {
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", existing)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return err
+		}
+		existing = parent
+	}
+	probe, err := os.CreateTemp(existing, ".tintlog-probe-*")
+	if err != nil {
+		return fmt.Errorf("%s is not writable: %w", existing, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+/*
+WatchConfig reloads the config file at path (with LoadConfig) whenever it
+changes, checking every interval, until stop is called.
+
+Levels are applied right away (log_level and component_levels, like SetLevel
+and SetComponentLevel), and so is sampling. Other fields can't change under a running logger:
+if they differ, a warning lists them and they take effect on restart.
+An invalid file is reported and the current config is kept.
+*/
+func (l *Logger) WatchConfig(path string, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stamp := func() (time.Time, int64) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return info.ModTime(), info.Size()
+	}
+	lastMod, lastSize := stamp()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			mod, size := stamp()
+			if size < 0 || (mod.Equal(lastMod) && size == lastSize) {
+				continue
+			}
+			lastMod, lastSize = mod, size
+			l.reloadConfig(path)
+		}
+	}()
+	return func() { close(done) }
+}
+
+// WatchConfig watches the config file of the default logger, see (*Logger).WatchConfig.
+func WatchConfig(path string, interval time.Duration) (stop func()) {
+	return std.WatchConfig(path, interval)
+}
+
+func (l *Logger) reloadConfig(path string) {
+	cfg, err, errMsg := LoadConfig(path)
+	if err != nil {
+		l.Log(Error, palette.Red, "Config not reloaded, err: '%s', errMsg: '%s'", err, errMsg)
+		return
+	}
+	if _, err := ApplyDefaults(cfg, cfg.explicitDefaults(defaultConfig()), nil); err != nil {
+		l.Log(Error, palette.Red, "Err: '%s', errMsg: '%s'", err, "Some logger config defaults are invalid, leaving those fields empty")
+	}
+
+	l.levels.reset(cfg)
+	l.sampler.reset(l, cfg.Sampling)
+	l.Log(Notice, palette.Blue, "Reloaded %s, log level %s", path, cfg.LogLevel.String())
+
+	// everything but the levels and sampling needs a restart
+	var restart []string
+	// levels can change l.Cfg at any time, see levelState
+	l.levels.mu.Lock()
+	current := *l.Cfg
+	l.levels.mu.Unlock()
+	cv, lv := reflect.ValueOf(*cfg), reflect.ValueOf(current)
+	for i := 0; i < cv.NumField(); i++ {
+		sf := cv.Type().Field(i)
+		key := jsonOrFieldName(sf)
+		if sf.Tag.Get("json") == "-" || key == "log_level" || key == "component_levels" || key == "sampling" {
+			continue
+		}
+		if !reflect.DeepEqual(cv.Field(i).Interface(), lv.Field(i).Interface()) {
+			restart = append(restart, key)
+		}
+	}
+	if len(restart) > 0 {
+		l.Log(Warning, palette.Yellow, "Config fields %s changed in %s, restart to apply them", strings.Join(restart, ", "), path)
+	}
+}
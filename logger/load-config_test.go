@@ -0,0 +1,56 @@
+package tl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// Critical is level 0, it must not be taken for a missing level
+func TestLoadConfigKeepsCriticalLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"logger": {"log_level": "Critical"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{"", "Critical", "0"} {
+		t.Setenv("TINTLOG_LEVEL", env)
+		file := path
+		if env != "" {
+			file = ""
+		}
+		cfg, err, errMsg := LoadConfig(file)
+		if err != nil {
+			t.Fatalf("%s: %s", errMsg, err)
+		}
+		l := NewLogger(cfg)
+		var out bytes.Buffer
+		l.SetOutput(&out)
+		l.Log(Debug, palette.Green, "debug")
+		if l.Level() != Critical || out.Len() > 0 {
+			t.Errorf("TINTLOG_LEVEL=%q: level %s, printed %q", env, l.Level(), out.String())
+		}
+	}
+}
+
+// a config of the whole program without a "logger" section leaves the logger config empty,
+// a bare logger section still has its unknown keys reported
+func TestLoadConfigSections(t *testing.T) {
+	for doc, wantErr := range map[string]bool{
+		`{"db": {"host": "localhost"}, "debug": true}`: false,
+		`{"logger": {"log_dir": "log"}, "db": {}}`:     false,
+		`{"log_dir": "log"}`:                           false,
+		`{"log_dir": "log", "log_levl": 5}`:            true,
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err, _ := LoadConfig(path)
+		if (err != nil) != wantErr {
+			t.Errorf("%s: err = %v", doc, err)
+		}
+	}
+}
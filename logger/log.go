@@ -3,29 +3,99 @@ package tl
 
 import (
 	"fmt"
-	"io"
-	"os"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/tuumbleweed/tintlog/palette"
 )
 
-var (
-	LoggerOutput      io.Writer = os.Stderr
-	LoggerOutputMutex sync.Mutex
-)
-
+// Log logs a line through the default logger, see (*Logger).Log.
 func Log(level LogLevel, colorize palette.Colorizer, format string, args ...any) {
-	LogBool(level, colorize, true, format, args...)
+	std.logBool(level, colorize, true, entry{}, format, args...)
 }
 
-// Log prints time (if TimeFormat != ""), [Level], optional [tid], then the message.
-// Prints to stderr only when Cfg.LogLevel >= level, but ALWAYS writes JSONL to file
-// if LoggerFilePath != "" (colorless), storing only color NAME + original format/args.
+// LogBool logs through the default logger, see (*Logger).LogBool.
 func LogBool(level LogLevel, colorize palette.Colorizer, newLine bool, format string, args ...any) {
+	std.logBool(level, colorize, newLine, entry{}, format, args...)
+}
+
+func (l *Logger) Log(level LogLevel, colorize palette.Colorizer, format string, args ...any) {
+	l.logBool(level, colorize, true, entry{}, format, args...)
+}
+
+// LogBool prints time (if TimeFormat != ""), [Level], optional [tid], the message
+// and then logger fields as key=value pairs.
+// Prints to output only when the level of this logger (see SetLevel, or the
+// component levels for a component logger) >= level, but ALWAYS writes JSONL to file
+// if a log file is open (colorless), storing only color NAME + original format/args.
+func (l *Logger) LogBool(level LogLevel, colorize palette.Colorizer, newLine bool, format string, args ...any) {
+	l.logBool(level, colorize, newLine, entry{}, format, args...)
+}
+
+// entry holds per-call extras of a log line
+type entry struct {
+	fields []Field   // merged over logger fields
+	stack  string    // goroutine stacks, saved to JSONL only
+	pc     uintptr   // call site if already known (slog), otherwise found with findCaller
+	time   time.Time // when the line was made if already known (slog), otherwise now
+	// a record about logging itself (see sampler.report): never sampled, no caller
+	internal bool
+}
+
+// logBool is LogBool with per-call extras.
+func (l *Logger) logBool(level LogLevel, colorize palette.Colorizer, newLine bool, e entry, format string, args ...any) {
+	// before any formatting, so suppressed lines cost little
+	if !l.sampled(level, format, e) {
+		return
+	}
+	fields := mergeFields(l.fields, e.fields)
+	cfg := l.Cfg
+	now := e.time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	tid := 0
+	if cfg.UseTid != nil && *cfg.UseTid {
+		tid = getTid()
+	}
+	var caller *Caller
+	if cfg.UseCaller != nil && *cfg.UseCaller && !e.internal {
+		if e.pc != 0 {
+			caller = callerFromPC(e.pc)
+		} else {
+			caller = findCaller(l.callerSkip)
+		}
+	}
+
+	// ----- ALWAYS write JSONL: original format + sanitized raw args (no ANSI) -----
+	l.writeLogJSONL(LogLine{
+		Time:      now,
+		TID:       tid,
+		Level:     level,
+		Color:     colorize.Name,
+		Format:    format,
+		Args:      sanitizeArgs(args),
+		Fields:    sanitizeFields(fields),
+		Stack:     e.stack,
+		Caller:    caller,
+		Component: l.component,
+	})
+
+	// ----- Print to stderr gated by level, nothing below is needed otherwise -----
+	if l.level() < level {
+		return
+	}
+
+	// ----- no ANSI at all when output is not a terminal or color is turned off -----
+	timeColor, callerColor := cfg.LogTimeColor, cfg.LogCallerColor
+	depth := l.colorDepth()
+	if depth == palette.DepthNone {
+		colorize = stripColor(colorize)
+		timeColor, callerColor = stripColor(timeColor), stripColor(callerColor)
+	}
+
 	// ----- colored args for stderr -----
 	coloredArgs := make([]any, len(args))
 	for i, a := range args {
@@ -34,16 +104,25 @@ func LogBool(level LogLevel, colorize palette.Colorizer, newLine bool, format st
 	}
 
 	bodyColored := fmt.Sprintf(format, coloredArgs...)
+	if len(fields) > 0 {
+		// fields go after the message but before a trailing newline
+		trail := ""
+		if strings.HasSuffix(bodyColored, "\n") {
+			trail = "\n"
+			bodyColored = strings.TrimSuffix(bodyColored, "\n")
+		}
+		bodyColored += " " + RenderFields(fields, colorize) + trail
+	}
 	if newLine && !strings.HasSuffix(bodyColored, "\n") {
 		bodyColored += "\n"
 	}
 
 	// ----- timestamp/prefix for stderr -----
 	ts := ""
-	if strings.TrimSpace(Cfg.TimeFormat) != "" {
-		raw := time.Now().Format(Cfg.TimeFormat)
-		if Cfg.LogTimeColor.Name != "" && Cfg.LogTimeColor.Fn != nil {
-			raw = Cfg.LogTimeColor.Fn(raw)
+	if strings.TrimSpace(cfg.TimeFormat) != "" {
+		raw := now.Format(cfg.TimeFormat)
+		if timeColor.Name != "" && timeColor.Fn != nil {
+			raw = timeColor.Fn(raw)
 		}
 		ts = raw + " "
 	}
@@ -55,30 +134,23 @@ func LogBool(level LogLevel, colorize palette.Colorizer, newLine bool, format st
 	}
 
 	prefix := "[" + levelStrColored + "] "
-	tid := 0
-	if Cfg.UseTid != nil && *Cfg.UseTid {
-		tid = getTid()
+	if cfg.UseTid != nil && *cfg.UseTid {
 		tidStr := strconv.Itoa(tid)
 		if colorize.Fn != nil {
 			tidStr = colorize.Fn(tidStr)
 		}
 		prefix = "[" + levelStrColored + "][" + tidStr + "] "
 	}
-
-	// ----- ALWAYS write JSONL: original format + sanitized raw args (no ANSI) -----
-	writeLogJSONL(LogLine{
-		Time:   time.Now(),
-		TID:    tid,
-		Level:  level,
-		Color:  colorize.Name,
-		Format: format,
-		Args:   sanitizeArgs(args),
-	})
-
-	// ----- Print to stderr gated by level -----
-	if Cfg.LogLevel >= level {
-		LoggerOutputMutex.Lock()
-		_, _ = io.WriteString(LoggerOutput, ts+prefix+bodyColored)
-		LoggerOutputMutex.Unlock()
+	if l.component != "" {
+		prefix = strings.TrimSuffix(prefix, " ") + "[" + colorize.Apply(l.component) + "] "
+	}
+	if caller != nil {
+		callerStr := caller.Short()
+		if cfg.FullCallerPath != nil && *cfg.FullCallerPath {
+			callerStr = caller.String()
+		}
+		prefix += callerColor.Apply(callerStr) + " "
 	}
+
+	l.out.write(palette.Downsample(ts+prefix+bodyColored, depth))
 }
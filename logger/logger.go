@@ -0,0 +1,128 @@
+package tl
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+/*
+Logger carries its own Config, stderr-like output and JSONL file sink.
+
+Package-level functions (Log, LogBool, LogJSON, LogRewrite, InitializeConfig,
+OpenLoggerFile) are thin wrappers around the default logger, which uses the
+package-level Cfg. Create additional loggers with NewLogger when one process
+needs several levels, outputs or log files.
+*/
+type Logger struct {
+	Cfg *Config
+
+	out    *output
+	sink   *fileSink
+	levels *levelState // runtime levels, see SetLevel
+	// drops lines over Cfg.Sampling limits
+	sampler *sampler
+	fields  []Field // attached to every line, see With
+	// extra frames to skip when looking for the caller, see WithCallerSkip
+	callerSkip int
+	// name for Cfg.ComponentLevels, see Component
+	component string
+}
+
+// output is a writer shared by a logger and every logger derived from it.
+// Pointers, so the default logger can use LoggerOutput and LoggerOutputMutex.
+type output struct {
+	w  *io.Writer
+	mu *sync.Mutex
+	// color decision for w, see colorDepth; guarded by mu
+	depth depthCache
+}
+
+func newOutput(w io.Writer) *output {
+	return &output{w: &w, mu: &sync.Mutex{}}
+}
+
+func (o *output) write(s string) {
+	o.mu.Lock()
+	_, _ = io.WriteString(*o.w, s)
+	o.mu.Unlock()
+}
+
+var (
+	// Deprecated: use SetOutput and Output. Still the output of the default logger,
+	// lock LoggerOutputMutex when replacing it while other goroutines log.
+	LoggerOutput io.Writer = os.Stderr
+	// Deprecated: use SetOutput and Output.
+	LoggerOutputMutex sync.Mutex
+)
+
+// default logger, backed by the package-level Cfg
+var std = &Logger{
+	Cfg:     &Cfg,
+	out:     &output{w: &LoggerOutput, mu: &LoggerOutputMutex},
+	sink:    &fileSink{mu: &LoggerFileMutex, publish: true},
+	levels:  newLevelState(&Cfg),
+	sampler: &sampler{},
+}
+
+// Default returns the logger used by package-level functions.
+func Default() *Logger {
+	return std
+}
+
+/*
+NewLogger creates a logger with its own config, writing to stderr.
+
+userConfig is applied the same way InitializeConfig does it: missing values are
+filled from the default config and a log file is opened if LogDir is set.
+Pass nil to keep the default config.
+*/
+func NewLogger(userConfig *Config) *Logger {
+	cfg := defaultConfig()
+	l := &Logger{
+		Cfg:     &cfg,
+		out:     newOutput(os.Stderr),
+		sink:    &fileSink{mu: &sync.Mutex{}},
+		levels:  newLevelState(&cfg),
+		sampler: &sampler{},
+	}
+	l.InitializeConfig(userConfig)
+	return l
+}
+
+// SetOutput replaces the writer that colored lines are printed to (os.Stderr by default).
+func (l *Logger) SetOutput(w io.Writer) {
+	l.out.mu.Lock()
+	*l.out.w = w
+	l.out.depth = depthCache{}
+	l.out.mu.Unlock()
+}
+
+// Output returns the writer that colored lines are printed to.
+func (l *Logger) Output() io.Writer {
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	return *l.out.w
+}
+
+// LogFilePath returns the path of the JSONL file, or "" if file logging is off.
+func (l *Logger) LogFilePath() string {
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+	return l.sink.path
+}
+
+// SetOutput replaces the output of the default logger.
+func SetOutput(w io.Writer) {
+	std.SetOutput(w)
+}
+
+// Output returns the output of the default logger.
+func Output() io.Writer {
+	return std.Output()
+}
+
+// LogFilePath returns the JSONL file path of the default logger, "" if file logging is off.
+func LogFilePath() string {
+	return std.LogFilePath()
+}
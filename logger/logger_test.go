@@ -0,0 +1,44 @@
+package tl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// the deprecated package variables still drive the default logger
+func TestDeprecatedGlobals(t *testing.T) {
+	var out bytes.Buffer
+	LoggerOutputMutex.Lock()
+	saved := LoggerOutput
+	LoggerOutput = &out
+	LoggerOutputMutex.Unlock()
+	defer func() { LoggerOutput = saved }()
+
+	Log(Info, palette.Green, "to %s", "LoggerOutput")
+	if !strings.Contains(out.String(), "to LoggerOutput") {
+		t.Errorf("output = %q", out.String())
+	}
+	if Output() != &out {
+		t.Error("Output() is not LoggerOutput")
+	}
+
+	dir := t.TempDir()
+	if err, errMsg := OpenLoggerFile(dir); err != nil {
+		t.Fatalf("%s: %s", errMsg, err)
+	}
+	LoggerFileMutex.Lock()
+	path, file := LoggerFilePath, LoggerFile
+	LoggerFileMutex.Unlock()
+	if path == "" || path != LogFilePath() || file == nil {
+		t.Errorf("LoggerFilePath = %q, LogFilePath() = %q", path, LogFilePath())
+	}
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	if LoggerFilePath != "" || LoggerFile != nil {
+		t.Errorf("after Close: LoggerFilePath = %q", LoggerFilePath)
+	}
+}
@@ -11,7 +11,11 @@ import (
 // Example:
 //   LogJSON(tl.Info, palette.CyanDim, "description", value)
 func LogJSON(level LogLevel, colorize palette.Colorizer, title string, value any) {
-	Log(level, colorize, "%s (JSON):\n'''\n%s\n'''", title, value)
+	std.LogJSON(level, colorize, title, value)
+}
+
+func (l *Logger) LogJSON(level LogLevel, colorize palette.Colorizer, title string, value any) {
+	l.Log(level, colorize, "%s (JSON):\n'''\n%s\n'''", title, value)
 }
 
 // LogRewrite writes a line prefixed with \r and WITHOUT a trailing newline,
@@ -19,5 +23,9 @@ func LogJSON(level LogLevel, colorize palette.Colorizer, title string, value any
 // Note: the carriage return is placed at the start of the message body;
 // ts/prefix still print normally, so the body portion is what's “rewritten”.
 func LogRewrite(level LogLevel, colorize palette.Colorizer, format string, args ...any) {
-	LogBool(level, colorize, false, "\r"+format+strings.Repeat(" ", 20), args...)
+	std.LogRewrite(level, colorize, format, args...)
+}
+
+func (l *Logger) LogRewrite(level LogLevel, colorize palette.Colorizer, format string, args ...any) {
+	l.LogBool(level, colorize, false, "\r"+format+strings.Repeat(" ", 20), args...)
 }
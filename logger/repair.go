@@ -0,0 +1,76 @@
+package tl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+RepairLogFile fixes a JSONL file whose last line was cut off, for example by
+a crash in the middle of a write.
+
+A last line without a newline is kept (and gets its newline) if it is valid
+JSON, otherwise it is truncated away and returned as dropped. Files that end
+with a newline are left as they are. Compressed (.gz) files can't be repaired.
+Don't repair a file a logger is still writing to.
+*/
+func RepairLogFile(path string) (dropped []byte, err error, errMsg string) {
+	if strings.HasSuffix(path, ".gz") {
+		return nil, fmt.Errorf("%s is compressed", path), "Unable to repair compressed file"
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err, fmt.Sprintf("Unable to open file: '%s'", path)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err, fmt.Sprintf("Unable to stat file: '%s'", path)
+	}
+	end, last, err := lastNewline(file, info.Size())
+	if err != nil {
+		return nil, err, fmt.Sprintf("Unable to read file: '%s'", path)
+	}
+	if end == info.Size() {
+		return nil, nil, ""
+	}
+
+	// the last line is only missing its newline
+	if json.Valid(bytes.TrimSpace(last)) {
+		if _, err = file.WriteAt([]byte("\n"), info.Size()); err != nil {
+			return nil, err, fmt.Sprintf("Unable to write to file: '%s'", path)
+		}
+		return nil, nil, ""
+	}
+	if err = file.Truncate(end); err != nil {
+		return nil, err, fmt.Sprintf("Unable to truncate file: '%s'", path)
+	}
+	return last, nil, ""
+}
+
+// lastNewline returns the offset just after the last newline in the first size bytes of r
+// and the bytes that follow it.
+func lastNewline(r io.ReaderAt, size int64) (end int64, last []byte, err error) {
+	pos := size
+	chunk := make([]byte, 64*1024)
+	for pos > 0 {
+		readSize := min(int64(len(chunk)), pos)
+		pos -= readSize
+		if _, err := r.ReadAt(chunk[:readSize], pos); err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+		nl := bytes.LastIndexByte(chunk[:readSize], '\n')
+		if nl >= 0 {
+			end = pos + int64(nl) + 1
+			last = append(append([]byte(nil), chunk[nl+1:readSize]...), last...)
+			return end, last, nil
+		}
+		last = append(append([]byte(nil), chunk[:readSize]...), last...)
+	}
+	return 0, last, nil
+}
@@ -0,0 +1,218 @@
+package tl
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retention settings copied from Config when the log file is opened
+type retention struct {
+	maxCount int
+	maxAge   time.Duration
+	compress bool
+}
+
+func (r retention) enabled() bool {
+	return r.maxCount > 0 || r.maxAge > 0 || r.compress
+}
+
+// parse Config.LogFileMaxAge: a Go duration or a number of days like "7d", "" means keep forever
+func parseMaxAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		d, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("max age must be positive, got %s", s)
+	}
+	return d, nil
+}
+
+/*
+ParseLogFileName reports whether name was produced by the sink for this format,
+including the .N suffix added by uniqueLogFilePath and the .gz of compressed files.
+It returns the time from the name and N (0 without a suffix) to order files.
+Only such files are compressed or removed, anything else in LogDir is left alone.
+*/
+func ParseLogFileName(name, format string) (t time.Time, seq int, ok bool) {
+	name = strings.TrimSuffix(name, ".gz")
+	// time.Parse reads the .N of "05.3.jsonl" as fractional seconds, so the name must format back
+	if t, err := time.Parse(format, name); err == nil && t.Format(format) == name {
+		return t, 0, true
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	dot := strings.LastIndex(base, ".")
+	if dot < 0 {
+		return time.Time{}, 0, false
+	}
+	seq, err := strconv.Atoi(base[dot+1:])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	t, err = time.Parse(format, base[:dot]+ext)
+	if err != nil || t.Format(format) != base[:dot]+ext {
+		return time.Time{}, 0, false
+	}
+	return t, seq, true
+}
+
+// startCleanupLocked runs cleanupLogDir in the background. Caller holds s.mu.
+func (s *fileSink) startCleanupLocked() {
+	if !s.retention.enabled() {
+		return
+	}
+	dir, format, r := s.dir, s.format, s.retention
+	s.cleanupWG.Add(1)
+	go func() {
+		defer s.cleanupWG.Done()
+		s.cleanupMu.Lock()
+		defer s.cleanupMu.Unlock()
+		// the file may have rotated again since this cleanup was started
+		s.mu.Lock()
+		current, rotated := s.path, maps.Clone(s.rotated)
+		s.mu.Unlock()
+		removed := cleanupLogDir(dir, format, current, rotated, r)
+		s.mu.Lock()
+		for _, path := range removed {
+			delete(s.rotated, strings.TrimSuffix(path, ".gz"))
+		}
+		s.mu.Unlock()
+	}()
+}
+
+/*
+Other processes (and other loggers) may write to the same LogDir. Their files
+are left alone until they haven't been written for this long, so a live file
+is never compressed or removed under its writer.
+*/
+const foreignLogFileIdle = 24 * time.Hour
+
+/*
+cleanupLogDir compresses log files other than current (if enabled), then removes
+files older than maxAge and the oldest files beyond maxCount, and returns the
+removed paths. Only files this sink rotated (rotated, by uncompressed path) and
+files idle for foreignLogFileIdle are touched.
+maxCount counts rotated files only, the current file is always kept.
+Errors are ignored: the next rotation tries again.
+*/
+func cleanupLogDir(dir, format, current string, rotated map[string]bool, r retention) (removed []string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+		// from the file name, mod times are too coarse to order files rotated in one burst
+		nameTime time.Time
+		seq      int
+	}
+	var files []logFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		nameTime, seq, ok := ParseLogFileName(e.Name(), format)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if path == current {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if !rotated[strings.TrimSuffix(path, ".gz")] && time.Since(info.ModTime()) < foreignLogFileIdle {
+			continue
+		}
+		if r.compress && !strings.HasSuffix(path, ".gz") {
+			if gzPath, err := gzipFile(path); err == nil {
+				path = gzPath
+			}
+		}
+		files = append(files, logFile{path: path, modTime: info.ModTime(), nameTime: nameTime, seq: seq})
+	}
+
+	// newest first
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].nameTime.Equal(files[j].nameTime) {
+			return files[i].nameTime.After(files[j].nameTime)
+		}
+		return files[i].seq > files[j].seq
+	})
+
+	now := time.Now()
+	for i, f := range files {
+		tooMany := r.maxCount > 0 && i >= r.maxCount
+		tooOld := r.maxAge > 0 && now.Sub(f.modTime) > r.maxAge
+		if tooMany || tooOld {
+			if os.Remove(f.path) == nil {
+				removed = append(removed, f.path)
+			}
+		}
+	}
+	return removed
+}
+
+// gzipFile compresses path into path.gz (keeping its mod time) and removes path.
+// An existing path.gz is never overwritten, path is kept instead.
+func gzipFile(path string) (gzPath string, err error) {
+	gzPath = path + ".gz"
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	dst, err := os.OpenFile(gzPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	zw.ModTime = info.ModTime()
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(gzPath)
+		return "", err
+	}
+
+	_ = os.Chtimes(gzPath, info.ModTime(), info.ModTime())
+	return gzPath, os.Remove(path)
+}
@@ -0,0 +1,169 @@
+package tl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parse Config.LogFileRotateEvery, "" means no time-based rotation
+func parseRotateEvery(s string) (time.Duration, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return 0, nil
+	case "hourly":
+		return time.Hour, nil
+	case "daily":
+		return 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("rotation interval must be positive, got %s", s)
+	}
+	return d, nil
+}
+
+/*
+nextRotation returns the first interval boundary after now.
+
+Intervals that evenly divide a day are aligned to local midnight, so "hourly"
+rotates at :00 and "6h" at 00:00, 06:00, 12:00 and 18:00.
+Other intervals are counted from now.
+*/
+func nextRotation(now time.Time, every time.Duration) time.Time {
+	day := 24 * time.Hour
+	if every > day || day%every != 0 {
+		return now.Add(every)
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	elapsed := now.Sub(midnight)
+	return midnight.Add((elapsed/every + 1) * every)
+}
+
+/*
+uniqueLogFilePath adds .N before the extension if the file already exists,
+so two rotations within one LogFileFormat tick don't write into the same file.
+N is one more than the highest N in dir, counting compressed (.gz) files:
+names of files removed by retention are never reused, so names keep the order
+files were written in (see cleanupLogDir).
+*/
+func uniqueLogFilePath(dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	next := 0 // 0 is name itself
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			other := strings.TrimSuffix(e.Name(), ".gz")
+			if other == name {
+				next = max(next, 1)
+				continue
+			}
+			n, ok := strings.CutPrefix(other, base+".")
+			if !ok {
+				continue
+			}
+			n, ok = strings.CutSuffix(n, ext)
+			if seq, err := strconv.Atoi(n); ok && err == nil && seq > 0 {
+				next = max(next, seq+1)
+			}
+		}
+	}
+	for i := next; ; i++ {
+		path := filepath.Join(dir, name)
+		if i > 0 {
+			path = filepath.Join(dir, fmt.Sprintf("%s.%d%s", base, i, ext))
+		}
+		if !fileExists(path) && !fileExists(path+".gz") {
+			return path
+		}
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return !os.IsNotExist(err)
+}
+
+// openLocked opens a new file named from s.format and resets rotation counters.
+// Caller holds s.mu.
+func (s *fileSink) openLocked(now time.Time) error {
+	path := uniqueLogFilePath(s.dir, now.Format(s.format))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		s.path = path
+		s.publishLocked()
+		return err
+	}
+	if s.bw != nil {
+		// lines buffered for the old file go there before it's closed
+		_ = s.bw.Flush()
+		s.bw.Reset(file)
+	}
+	s.file, s.path, s.size = file, path, 0
+	s.publishLocked()
+	s.opened.Store(true)
+	if s.every > 0 {
+		s.nextRotate = nextRotation(now, s.every)
+	}
+	return nil
+}
+
+// publishLocked updates LoggerFile and LoggerFilePath if this is the default logger's sink.
+// Caller holds s.mu, which is LoggerFileMutex then.
+func (s *fileSink) publishLocked() {
+	if s.publish {
+		LoggerFile, LoggerFilePath = s.file, s.path
+	}
+}
+
+// rotateLocked closes the current file and opens the next one. Caller holds s.mu.
+// On error the old file is kept so lines are not lost.
+func (s *fileSink) rotateLocked(now time.Time) {
+	old, oldPath := s.file, s.path
+	if err := s.openLocked(now); err != nil {
+		s.path = oldPath
+		s.publishLocked()
+		if s.every > 0 {
+			s.nextRotate = nextRotation(now, s.every)
+		}
+		return
+	}
+	_ = old.Close()
+	s.markRotatedLocked(oldPath)
+	s.startCleanupLocked()
+}
+
+// markRotatedLocked records that path was written by this sink and is done. Caller holds s.mu.
+func (s *fileSink) markRotatedLocked(path string) {
+	if s.rotated == nil {
+		s.rotated = make(map[string]bool)
+	}
+	s.rotated[path] = true
+}
+
+// writeLocked writes one JSONL record, rotating first if it's due. Caller holds s.mu.
+func (s *fileSink) writeLocked(b []byte) {
+	if s.file == nil {
+		return
+	}
+	now := time.Now()
+	dueBySize := s.maxSize > 0 && s.size > 0 && s.size+int64(len(b)) > s.maxSize
+	dueByTime := s.every > 0 && !now.Before(s.nextRotate)
+	if dueBySize || dueByTime {
+		s.rotateLocked(now)
+	}
+	var n int
+	if s.bw != nil {
+		n, _ = s.bw.Write(b)
+	} else {
+		n, _ = s.file.Write(b)
+	}
+	s.size += int64(n)
+}
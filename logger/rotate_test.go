@@ -0,0 +1,172 @@
+package tl
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// every line must survive rotation, also when rotated files are compressed
+func TestRotationKeepsAllLines(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run("compress="+strconv.FormatBool(compress), func(t *testing.T) {
+			dir := t.TempDir()
+			l := NewLogger(&Config{
+				LogDir:           dir,
+				LogFileMaxSize:   2000,
+				CompressLogFiles: &compress,
+			})
+			l.SetOutput(io.Discard)
+
+			const lines = 300
+			for i := 0; i < lines; i++ {
+				l.Log(Info, palette.Green, "line %s", strconv.Itoa(i))
+				// let the cleanup of every rotation finish, like a slow logger would
+				l.sink.cleanupWG.Wait()
+			}
+			if err := l.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			seen := make(map[string]bool)
+			for _, line := range readLogDir(t, dir) {
+				if line.Format == "line %s" {
+					seen[line.Args[0].(string)] = true
+				}
+			}
+			if len(seen) != lines {
+				t.Errorf("found %d of %d lines", len(seen), lines)
+			}
+		})
+	}
+}
+
+// readLogDir reads every .jsonl and .jsonl.gz file in dir
+func readLogDir(t *testing.T, dir string) []LogLine {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []LogLine
+	for _, e := range entries {
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(e.Name(), ".gz") {
+			zr, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("%s: %s", e.Name(), err)
+			}
+			r = zr
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var line LogLine
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Fatalf("%s: %s", e.Name(), err)
+			}
+			lines = append(lines, line)
+		}
+		f.Close()
+	}
+	return lines
+}
+
+// a second logger in the same LogDir must not compress or remove the live file of the first
+func TestCleanupLeavesOtherWritersAlone(t *testing.T) {
+	dir := t.TempDir()
+	compress := true
+	first := NewLogger(&Config{LogDir: dir})
+	first.SetOutput(io.Discard)
+	second := NewLogger(&Config{LogDir: dir, LogFileMaxSize: 2000, LogFileMaxCount: 1, CompressLogFiles: &compress})
+	second.SetOutput(io.Discard)
+
+	for i := 0; i < 100; i++ {
+		first.Log(Info, palette.Green, "first %s", strconv.Itoa(i))
+		second.Log(Info, palette.Green, "second %s", strconv.Itoa(i))
+		second.sink.cleanupWG.Wait()
+	}
+	for _, l := range []*Logger{first, second} {
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := 0
+	for _, line := range readLogDir(t, dir) {
+		if line.Format == "first %s" {
+			n++
+		}
+	}
+	if n != 100 {
+		t.Errorf("found %d of 100 lines of the first logger", n)
+	}
+}
+
+// retention past LogFileMaxCount must remove the oldest lines and keep the newest
+func TestRetentionKeepsNewestLines(t *testing.T) {
+	dir := t.TempDir()
+	compress := true
+	l := NewLogger(&Config{
+		LogDir:           dir,
+		LogFileMaxSize:   2000,
+		LogFileMaxCount:  3,
+		CompressLogFiles: &compress,
+	})
+	l.SetOutput(io.Discard)
+
+	const lines = 300
+	for i := 0; i < lines; i++ {
+		l.Log(Info, palette.Green, "line %s", strconv.Itoa(i))
+		l.sink.cleanupWG.Wait()
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[int]bool)
+	oldest := lines
+	for _, line := range readLogDir(t, dir) {
+		if line.Format == "line %s" {
+			i, _ := strconv.Atoi(line.Args[0].(string))
+			seen[i] = true
+			oldest = min(oldest, i)
+		}
+	}
+	if oldest == 0 {
+		t.Fatalf("no lines were removed")
+	}
+	for i := oldest; i < lines; i++ {
+		if !seen[i] {
+			t.Errorf("line %d is missing, kept lines are from %d", i, oldest)
+		}
+	}
+}
+
+// the .N suffix must not be read as fractional seconds of the name time
+func TestParseLogFileNameSeq(t *testing.T) {
+	format := "02_Jan_2006_15_04_05.jsonl"
+	want, _ := time.Parse(format, "16_Oct_2026_20_10_05.jsonl")
+	for name, seq := range map[string]int{
+		"16_Oct_2026_20_10_05.jsonl":       0,
+		"16_Oct_2026_20_10_05.7.jsonl":     7,
+		"16_Oct_2026_20_10_05.14.jsonl.gz": 14,
+	} {
+		got, gotSeq, ok := ParseLogFileName(name, format)
+		if !ok || gotSeq != seq || !got.Equal(want) {
+			t.Errorf("%s: got %s, %d, %v", name, got, gotSeq, ok)
+		}
+	}
+}
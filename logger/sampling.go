@@ -0,0 +1,270 @@
+package tl
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// What lines are counted together by sampling, see SamplingConfig.By.
+const (
+	// lines with the same format string
+	SampleByFormat = "format"
+	// lines logged from the same file:line
+	SampleByCaller = "caller"
+)
+
+/*
+SamplingConfig limits how many lines a hot call site can log, see Config.Sampling.
+
+Lines are counted per format string (or per call site, see By). Within every
+second the first First lines are kept, then one in Thereafter. With Rate set,
+kept lines also go through a token bucket of Rate lines per second that holds
+up to Burst lines. Suppressed lines are neither printed nor saved to file;
+every ReportEvery a "Suppressed N similar lines" record is logged per format
+(or call site), at the most important level suppressed.
+
+	"sampling": {"first": 100, "thereafter": 1000, "above": "Notice9"}
+
+The Critical band is never sampled.
+*/
+type SamplingConfig struct {
+	// lines kept per second before sampling starts, 0 to not sample
+	First int `json:"first,omitempty"`
+	// after First, keep one in this many lines in the same second; 0 drops them all
+	Thereafter int `json:"thereafter,omitempty"`
+	// token bucket: lines per second on average, 0 to not limit
+	Rate float64 `json:"rate,omitempty"`
+	// token bucket: lines allowed at once, 0 for Rate rounded up
+	Burst int `json:"burst,omitempty"`
+	// count lines by "format" string or by "caller" (file:line)
+	By string `json:"by,omitempty" default:"format"`
+	// only lines with a level above this one are sampled, for example Notice9 to keep warnings
+	Above LogLevel `json:"above,omitempty"`
+	// how often suppressed lines are reported, a duration like "10s"
+	ReportEvery string `json:"report_every,omitempty" default:"10s"`
+}
+
+func (c *SamplingConfig) validate() error {
+	switch {
+	case c.First < 0 || c.Thereafter < 0 || c.Burst < 0:
+		return fmt.Errorf("first, thereafter and burst must not be negative")
+	case c.Rate < 0:
+		return fmt.Errorf("rate must not be negative")
+	}
+	switch c.By {
+	case "", SampleByFormat, SampleByCaller:
+	default:
+		return fmt.Errorf("by: %q is not %s or %s", c.By, SampleByFormat, SampleByCaller)
+	}
+	if err := validateLevel(c.Above); err != nil {
+		return fmt.Errorf("above: %w", err)
+	}
+	if _, err := parseReportEvery(c.ReportEvery); err != nil {
+		return fmt.Errorf("report_every: %w", err)
+	}
+	return nil
+}
+
+func parseReportEvery(s string) (time.Duration, error) {
+	if s == "" {
+		return 10 * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("%q is not positive", s)
+	}
+	return d, err
+}
+
+/*
+sampler drops lines over the SamplingConfig limits and reports how many it dropped.
+Shared by every logger derived from the one it was configured on.
+*/
+type sampler struct {
+	// nil when not sampling, replaced (never modified) by reset
+	cfg atomic.Pointer[SamplingConfig]
+
+	mu   sync.Mutex
+	keys map[string]*sampleCount
+	// reports go through the logger the sampler was configured on
+	logger *Logger
+	stop   chan struct{}
+}
+
+// sampleCount is the state of one format string or call site
+type sampleCount struct {
+	second int64 // unix second the count is for
+	n      int   // lines in that second
+	tokens float64
+	filled time.Time // last token bucket refill
+	// lines suppressed since the last report, and the most important level among them
+	suppressed int
+	level      LogLevel
+	// logged since the last report, keys that weren't are forgotten
+	seen bool
+}
+
+/*
+reset applies c (nil to stop sampling). Pending counts are reported first;
+counts are kept, so no suppressed line goes unreported across a reset.
+*/
+func (s *sampler) reset(l *Logger, c *SamplingConfig) {
+	s.report()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	if c == nil || (c.First <= 0 && c.Rate <= 0) {
+		s.cfg.Store(nil)
+		return
+	}
+	every, err := parseReportEvery(c.ReportEvery)
+	if err != nil {
+		every, _ = parseReportEvery("")
+	}
+	cfg := *c
+	s.logger = l
+	if s.keys == nil {
+		s.keys = make(map[string]*sampleCount)
+	}
+	s.stop = make(chan struct{})
+	go s.reportEvery(every, s.stop)
+	s.cfg.Store(&cfg)
+}
+
+// close reports pending counts and stops sampling and the report goroutine
+func (s *sampler) close() {
+	s.reset(nil, nil)
+}
+
+func (s *sampler) reportEvery(every time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.report()
+		}
+	}
+}
+
+// sampled reports whether a line should be logged, counting it either way
+func (l *Logger) sampled(level LogLevel, format string, e entry) bool {
+	c := l.sampler.cfg.Load()
+	if c == nil || e.internal || level <= Critical9 || level <= c.Above {
+		return true
+	}
+	key := format
+	if c.By == SampleByCaller {
+		var caller *Caller
+		if e.pc != 0 {
+			caller = callerFromPC(e.pc)
+		} else {
+			caller = findCaller(l.callerSkip)
+		}
+		if caller != nil {
+			key = caller.String()
+		}
+	}
+	return l.sampler.allow(c, level, key)
+}
+
+func (s *sampler) allow(c *SamplingConfig, level LogLevel, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+
+	count := s.keys[key]
+	if count == nil {
+		count = &sampleCount{tokens: c.burst(), filled: now}
+		s.keys[key] = count
+	}
+	count.seen = true
+
+	allowed := true
+	if c.First > 0 {
+		if second := now.Unix(); second != count.second {
+			count.second, count.n = second, 0
+		}
+		count.n++
+		if count.n > c.First && (c.Thereafter <= 0 || (count.n-c.First)%c.Thereafter != 0) {
+			allowed = false
+		}
+	}
+	if allowed && c.Rate > 0 {
+		count.tokens = min(c.burst(), count.tokens+now.Sub(count.filled).Seconds()*c.Rate)
+		count.filled = now
+		if count.tokens >= 1 {
+			count.tokens--
+		} else {
+			allowed = false
+		}
+	}
+
+	if !allowed {
+		if count.suppressed == 0 || level < count.level {
+			count.level = level
+		}
+		count.suppressed++
+	}
+	return allowed
+}
+
+// burst is the token bucket size
+func (c *SamplingConfig) burst() float64 {
+	if c.Burst > 0 {
+		return float64(c.Burst)
+	}
+	return max(1, math.Ceil(c.Rate))
+}
+
+// report logs a record for every key with suppressed lines and forgets keys that weren't logged since the last report
+func (s *sampler) report() {
+	type suppressed struct {
+		key   string
+		n     int
+		level LogLevel
+	}
+	var pending []suppressed
+
+	by := SampleByFormat
+	if c := s.cfg.Load(); c != nil && c.By != "" {
+		by = c.By
+	}
+	s.mu.Lock()
+	l := s.logger
+	for key, count := range s.keys {
+		if count.suppressed > 0 {
+			pending = append(pending, suppressed{key, count.suppressed, count.level})
+			count.suppressed = 0
+		} else if !count.seen {
+			delete(s.keys, key)
+		}
+		count.seen = false
+	}
+	s.mu.Unlock()
+
+	if l == nil {
+		return
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].key < pending[j].key })
+	for _, p := range pending {
+		l.logBool(
+			p.level, palette.Yellow, true,
+			entry{fields: []Field{F(by, p.key)}, internal: true},
+			"%s %s similar lines", "Suppressed", strconv.Itoa(p.n),
+		)
+	}
+}
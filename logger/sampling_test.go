@@ -0,0 +1,28 @@
+package tl
+
+import (
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Close must stop the report goroutine of every sampling logger
+func TestCloseStopsSampler(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		l := NewLogger(&Config{Sampling: &SamplingConfig{First: 1, ReportEvery: "1ms"}})
+		l.SetOutput(io.Discard)
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines left after Close, want %d", n, before)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package tl
+
+// HandleLevelSignals does nothing on systems without SIGUSR1 and SIGUSR2.
+func (l *Logger) HandleLevelSignals() (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package tl
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+/*
+HandleLevelSignals lets the level be changed from outside the process:
+SIGUSR1 moves it one band up (more verbose) and SIGUSR2 one band down,
+see StepBand. For example "kill -USR1 <pid>" turns Info5 into Detailed9.
+Call the returned function to stop handling the signals.
+*/
+func (l *Logger) HandleLevelSignals() (stop func()) {
+	signals := make(chan os.Signal, 8)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				step := 1
+				if sig == syscall.SIGUSR2 {
+					step = -1
+				}
+				level := StepBand(l.Level(), step)
+				l.SetLevel(level)
+				l.Log(Notice, palette.Blue, "Log level set to %s by %s", level.String(), sig.String())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
@@ -0,0 +1,173 @@
+package tl
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/tuumbleweed/tintlog/palette"
+)
+
+// SlogHandlerOptions customize a SlogHandler. A nil option keeps the default.
+type SlogHandlerOptions struct {
+	// maps slog levels onto LogLevel, SlogLevelToLogLevel by default
+	LevelMap func(slog.Level) LogLevel
+	// picks a colorizer for a line, DefaultLevelColorizer by default
+	Colorizer func(LogLevel) palette.Colorizer
+}
+
+/*
+SlogHandler is a slog.Handler that logs through a Logger.
+
+Records are printed and saved to JSONL exactly like Log calls: the message
+becomes the format string and attributes become fields (group names are
+joined with dots, for example "http.status"). Attribute values are tinted
+with the colorizer picked for the record level.
+*/
+type SlogHandler struct {
+	logger *Logger
+	opts   SlogHandlerOptions
+	// group prefix for attributes added after WithGroup, like "http."
+	prefix string
+}
+
+// NewSlogHandler returns a slog.Handler writing through l (the default logger if l is nil).
+func NewSlogHandler(l *Logger, opts *SlogHandlerOptions) *SlogHandler {
+	if l == nil {
+		l = std
+	}
+	h := &SlogHandler{logger: l}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.LevelMap == nil {
+		h.opts.LevelMap = SlogLevelToLogLevel
+	}
+	if h.opts.Colorizer == nil {
+		h.opts.Colorizer = DefaultLevelColorizer
+	}
+	return h
+}
+
+// Slog returns a *slog.Logger backed by this logger.
+func (l *Logger) Slog() *slog.Logger {
+	return slog.New(NewSlogHandler(l, nil))
+}
+
+/*
+SlogLevelToLogLevel maps slog levels onto the 0-99 scale:
+
+	slog.LevelError+4 and above -> Critical
+	slog.LevelError             -> Error
+	slog.LevelWarn              -> Warning
+	slog.LevelInfo              -> Info
+	slog.LevelDebug             -> Debug
+
+Levels between two slog levels map to the band of the lower one.
+*/
+func SlogLevelToLogLevel(level slog.Level) LogLevel {
+	switch {
+	case level >= slog.LevelError+4:
+		return Critical
+	case level >= slog.LevelError:
+		return Error
+	case level >= slog.LevelWarn:
+		return Warning
+	case level >= slog.LevelInfo:
+		return Info
+	case level >= slog.LevelDebug:
+		return Debug
+	default:
+		return Debug9
+	}
+}
+
+// DefaultLevelColorizer picks a colorizer by level band.
+func DefaultLevelColorizer(level LogLevel) palette.Colorizer {
+	switch {
+	case level < Error:
+		return palette.RedBoldBackground
+	case level < Warning:
+		return palette.RedBold
+	case level < Important:
+		return palette.Yellow
+	case level < Notice:
+		return palette.PurpleBold
+	case level < Info:
+		return palette.Blue
+	case level < Detailed:
+		return palette.Green
+	case level < Verbose:
+		return palette.Cyan
+	case level < Debug:
+		return palette.GrayBright
+	default:
+		return palette.GrayDim
+	}
+}
+
+// Enabled reports whether a record would be printed or saved to the log file.
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.logger.sink.opened.Load() || h.logger.level() >= h.opts.LevelMap(level)
+}
+
+// Handle logs the record with its attributes as fields.
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	level := h.opts.LevelMap(r.Level)
+
+	var fields []Field
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+
+	// message is not a format string, escape verbs
+	format := strings.ReplaceAll(r.Message, "%", "%%")
+	h.logger.logBool(level, h.opts.Colorizer(level), true, entry{fields: fields, pc: r.PC, time: r.Time}, format)
+	return nil
+}
+
+// WithAttrs returns a handler whose logger carries attrs as fields.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []Field
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.prefix, a)
+	}
+	derived := *h
+	derived.logger = h.logger.WithFields(fields...)
+	return &derived
+}
+
+// WithGroup returns a handler that qualifies the following attributes with name.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	derived := *h
+	derived.prefix = h.prefix + name + "."
+	return &derived
+}
+
+// appendAttr flattens an attribute (and nested groups) into dotted-key fields
+func appendAttr(fields []Field, prefix string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		if len(group) == 0 {
+			return fields
+		}
+		// a group with an empty key is inlined
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range group {
+			fields = appendAttr(fields, groupPrefix, ga)
+		}
+		return fields
+	}
+	return append(fields, F(prefix+a.Key, a.Value.Any()))
+}
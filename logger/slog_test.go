@@ -0,0 +1,35 @@
+package tl
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// the JSONL line keeps the time of the record, not the time it was handled
+func TestSlogHandlerKeepsRecordTime(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLogger(&Config{LogDir: dir})
+	l.SetOutput(io.Discard)
+
+	recorded := time.Date(2025, time.November, 9, 18, 19, 26, 0, time.UTC)
+	r := slog.NewRecord(recorded, slog.LevelInfo, "replayed", 0)
+	if err := l.Slog().Handler().Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range readLogDir(t, dir) {
+		if line.Format == "replayed" {
+			if !line.Time.Equal(recorded) {
+				t.Errorf("time = %s, want %s", line.Time, recorded)
+			}
+			return
+		}
+	}
+	t.Error("the replayed line was not written")
+}
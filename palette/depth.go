@@ -0,0 +1,288 @@
+package palette
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ColorDepth is how many colors a terminal can show.
+type ColorDepth int
+
+const (
+	// 24-bit "38;2;R;G;B" sequences, what every colorizer emits
+	DepthTrueColor ColorDepth = iota
+	// xterm-256 "38;5;N" sequences
+	Depth256
+	// basic ANSI 30-37/90-97 sequences
+	Depth16
+	// no escape sequences at all
+	DepthNone
+)
+
+func (d ColorDepth) String() string {
+	switch d {
+	case DepthTrueColor:
+		return "truecolor"
+	case Depth256:
+		return "256"
+	case Depth16:
+		return "16"
+	case DepthNone:
+		return "none"
+	}
+	return "ColorDepth(" + strconv.Itoa(int(d)) + ")"
+}
+
+/*
+ParseColorDepth parses "truecolor" (or "24bit"), "256", "16" or "none".
+"auto" and "" return DetectColorDepth(). ok is false for anything else.
+*/
+func ParseColorDepth(s string) (depth ColorDepth, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return DetectColorDepth(), true
+	case "truecolor", "24bit":
+		return DepthTrueColor, true
+	case "256":
+		return Depth256, true
+	case "16":
+		return Depth16, true
+	case "none":
+		return DepthNone, true
+	}
+	return DepthTrueColor, false
+}
+
+/*
+DetectColorDepth guesses the terminal color depth from the environment:
+  - COLORTERM=truecolor or 24bit -> DepthTrueColor
+  - TERM=dumb -> DepthNone
+  - TERM containing "truecolor", "24bit" or "direct" -> DepthTrueColor
+  - TERM containing "256color" -> Depth256
+  - TERM=linux, vt100, vt220, ansi, cons25 -> Depth16
+  - any other TERM -> Depth256
+  - no TERM at all (e.g. Windows) -> DepthTrueColor
+*/
+func DetectColorDepth() ColorDepth {
+	colorTerm := strings.ToLower(os.Getenv("COLORTERM"))
+	if colorTerm == "truecolor" || colorTerm == "24bit" {
+		return DepthTrueColor
+	}
+	term := strings.ToLower(os.Getenv("TERM"))
+	switch {
+	case term == "":
+		return DepthTrueColor
+	case term == "dumb":
+		return DepthNone
+	case strings.Contains(term, "truecolor"), strings.Contains(term, "24bit"), strings.Contains(term, "direct"):
+		return DepthTrueColor
+	case strings.Contains(term, "256color"):
+		return Depth256
+	}
+	switch term {
+	case "linux", "vt100", "vt220", "ansi", "cons25":
+		return Depth16
+	}
+	return Depth256
+}
+
+/*
+Downsample rewrites the color escape sequences in s for a terminal with the given depth.
+Truecolor ("38;2;R;G;B") and 256-color ("38;5;N") foregrounds and backgrounds
+are mapped to the nearest color by CIELAB distance; bold and reset are kept.
+DepthNone strips every SGR sequence. DepthTrueColor returns s unchanged.
+*/
+func Downsample(s string, depth ColorDepth) string {
+	if depth == DepthTrueColor || !strings.Contains(s, "\x1b[") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for {
+		start := strings.Index(s, "\x1b[")
+		if start < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := strings.IndexByte(s[start:], 'm')
+		if end < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end += start
+		params := s[start+2 : end]
+		b.WriteString(s[:start])
+		if !isSGRParams(params) {
+			// some other sequence, keep it
+			b.WriteString(s[start : end+1])
+		} else if depth != DepthNone {
+			b.WriteString("\x1b[" + downsampleParams(params, depth) + "m")
+		}
+		s = s[end+1:]
+	}
+}
+
+func isSGRParams(params string) bool {
+	for i := 0; i < len(params); i++ {
+		if (params[i] < '0' || params[i] > '9') && params[i] != ';' {
+			return false
+		}
+	}
+	return true
+}
+
+// downsampleParams rewrites extended colors in an SGR parameter list like "1;38;2;70;166;95"
+func downsampleParams(params string, depth ColorDepth) string {
+	parts := strings.Split(params, ";")
+	out := make([]string, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		p := parts[i]
+		if (p != "38" && p != "48") || i+1 >= len(parts) {
+			out = append(out, p)
+			continue
+		}
+		bg := p == "48"
+		var rgb RGB
+		switch {
+		case parts[i+1] == "2" && i+4 < len(parts):
+			rgb = RGB{atoiByte(parts[i+2]), atoiByte(parts[i+3]), atoiByte(parts[i+4])}
+			i += 4
+		case parts[i+1] == "5" && i+2 < len(parts):
+			rgb = xterm256RGB(int(atoiByte(parts[i+2])))
+			i += 2
+		default:
+			out = append(out, p)
+			continue
+		}
+		if depth == Depth256 {
+			out = append(out, p, "5", strconv.Itoa(Nearest256(rgb)))
+		} else {
+			out = append(out, strconv.Itoa(ansi16Code(Nearest16(rgb), bg)))
+		}
+	}
+	return strings.Join(out, ";")
+}
+
+func atoiByte(s string) uint8 {
+	n, _ := strconv.Atoi(s)
+	return uint8(max(0, min(255, n)))
+}
+
+// SGR code for one of the 16 basic colors (0-7 normal, 8-15 bright)
+func ansi16Code(idx int, bg bool) int {
+	base := 30
+	if idx >= 8 {
+		base = 90
+		idx -= 8
+	}
+	if bg {
+		base += 10
+	}
+	return base + idx
+}
+
+// xterm default values of the 16 basic colors
+var ansi16RGB = [16]RGB{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// levels of the 6x6x6 color cube (indices 16-231)
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// xterm256RGB returns the default RGB value of an xterm-256 color index
+func xterm256RGB(idx int) RGB {
+	switch {
+	case idx < 16:
+		return ansi16RGB[idx]
+	case idx < 232:
+		idx -= 16
+		return RGB{cubeLevels[idx/36], cubeLevels[idx/6%6], cubeLevels[idx%6]}
+	default:
+		v := uint8(8 + (idx-232)*10)
+		return RGB{v, v, v}
+	}
+}
+
+// lab colors of palettes we match against, computed once
+var (
+	lab256 = func() (labs [256]lab) {
+		for i := range labs {
+			labs[i] = toLab(xterm256RGB(i))
+		}
+		return labs
+	}()
+	lab16 = func() (labs [16]lab) {
+		for i := range labs {
+			labs[i] = toLab(ansi16RGB[i])
+		}
+		return labs
+	}()
+)
+
+/*
+Nearest256 returns the xterm-256 index closest to c.
+Only the color cube and the gray ramp (16-255) are considered,
+since the first 16 colors are usually redefined by terminal themes.
+*/
+func Nearest256(c RGB) int {
+	target := toLab(c)
+	best, bestDist := 16, math.MaxFloat64
+	for i := 16; i < 256; i++ {
+		if d := target.dist(lab256[i]); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// Nearest16 returns the index (0-15) of the basic ANSI color closest to c.
+func Nearest16(c RGB) int {
+	target := toLab(c)
+	best, bestDist := 0, math.MaxFloat64
+	for i := range lab16 {
+		if d := target.dist(lab16[i]); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// lab is a CIELAB color (D65 white point)
+type lab struct{ l, a, b float64 }
+
+// squared CIE76 distance, good enough to pick the nearest palette entry
+func (x lab) dist(y lab) float64 {
+	dl, da, db := x.l-y.l, x.a-y.a, x.b-y.b
+	return dl*dl + da*da + db*db
+}
+
+func toLab(c RGB) lab {
+	// sRGB -> linear RGB
+	lin := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.04045 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	r, g, b := lin(c.R), lin(c.G), lin(c.B)
+
+	// linear RGB -> XYZ, normalized by the D65 white point
+	x := (0.4124564*r + 0.3575761*g + 0.1804375*b) / 0.95047
+	y := 0.2126729*r + 0.7151522*g + 0.0721750*b
+	z := (0.0193339*r + 0.1191920*g + 0.9503041*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return lab{l: 116*fy - 16, a: 500 * (fx - fy), b: 200 * (fy - fz)}
+}